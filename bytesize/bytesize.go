@@ -0,0 +1,67 @@
+// Package bytesize parses and prints sizes in bytes e.g. "500M",
+// for flags such as cache sizes and download rates
+package bytesize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Size is a number of bytes which can be given with
+// a K, M, G or T (powers of 1024) suffix e.g. "500M".
+// It implements flag.Value.
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  Size
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// Parse parses @s e.g. "4G", "500M" or "1024"
+func Parse(s string) (Size, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "B")
+
+	multiplier := Size(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, multiplier = strings.TrimSuffix(value, u.suffix), u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid size %q, expected a number of bytes optionally followed by K, M, G or T", s)
+	}
+	if n > math.MaxInt64/int64(multiplier) {
+		return 0, fmt.Errorf("Invalid size %q, it's more than %v bytes", s, Size(math.MaxInt64))
+	}
+	return Size(n) * multiplier, nil
+}
+
+func (s Size) String() string {
+	for _, u := range sizeUnits {
+		if s != 0 && s%u.bytes == 0 {
+			return fmt.Sprintf("%d%s", s/u.bytes, u.suffix)
+		}
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Set implements flag.Value
+func (s *Size) Set(value string) error {
+	size, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
@@ -0,0 +1,53 @@
+package bytesize
+
+import "testing"
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Size
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"500M", 500 << 20, false},
+		{"4G", 4 << 30, false},
+		{"4gb", 4 << 30, false},
+		{"2T", 2 << 40, false},
+		{"16k", 16 << 10, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"-1G", 0, true},
+		{"1.5G", 0, true},
+		{"1X", 0, true},
+		{"8388607T", 8388607 << 40, false},
+		{"8388608T", 0, true},
+		{"99999999999G", 0, true},
+		{"9223372036854775807", 9223372036854775807, false},
+		{"9223372036854775808", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q)\nExpected: %v, error %v,\nactual %v, %v", tt.s, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+func Test_Size_String(t *testing.T) {
+	tests := []struct {
+		size Size
+		want string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{1024, "1K"},
+		{4 << 30, "4G"},
+		{1536 << 20, "1536M"},
+	}
+	for _, tt := range tests {
+		if got := tt.size.String(); got != tt.want {
+			t.Errorf("Size(%d).String()\nExpected: %q,\nactual %q", tt.size, tt.want, got)
+		}
+	}
+}
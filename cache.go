@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/pmalek/kernel_deb_downloader/cache"
+)
+
+// runCache implements the cache subcommand managing the download
+// cache shared across runs. It returns the process exit code.
+func runCache(args []string) int {
+	if len(args) == 0 || args[0] != "prune" {
+		fmt.Println("Usage: cache prune [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("cache prune", flag.ExitOnError)
+	dir := fs.String("dir", cacheDir, "Cache directory")
+	maxSize := cacheMaxSize
+	fs.Var(&maxSize, "max-size", "Prune the cache down to `size` e.g. \"500M\", least recently used .debs are removed first, 0 empties it")
+	fs.Parse(args[1:])
+
+	if *dir == "" {
+		fmt.Println("No cache directory was given")
+		return 2
+	}
+
+	removed, freed, err := cache.New(*dir, maxSize).Prune(maxSize)
+	if err != nil {
+		fmt.Printf("Error pruning cache %s: %v\n", *dir, err)
+		return 1
+	}
+
+	fmt.Printf("Removed %d .debs (%d bytes) from %s\n", removed, freed, *dir)
+	return 0
+}
@@ -0,0 +1,233 @@
+// Package cache keeps downloaded files across runs so that
+// identical .debs aren't downloaded again
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmalek/kernel_deb_downloader/bytesize"
+)
+
+// dirName is the name of the cache directory in the user's cache directory
+const dirName = "kernel_deb_downloader"
+
+// DefaultDir returns the default cache directory, under
+// $XDG_CACHE_HOME (or ~/.cache) on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, dirName), nil
+}
+
+// DefaultMaxSize is the default limit of the cache size
+const DefaultMaxSize = 4 * bytesize.Size(1<<30)
+
+// Cache stores files keyed by the URL they were downloaded from
+// and their checksum. Files are placed in and out of the cache with
+// hard links when possible and copied otherwise. It's safe to use
+// from multiple goroutines and processes sharing the same directory:
+// getting and putting files holds a shared lock on the directory's
+// lock file and pruning an exclusive one, so that entries aren't
+// removed while they're being placed.
+type Cache struct {
+	// Dir is the directory files are kept in
+	Dir string
+	// MaxSize is the size the cache is pruned to after
+	// each file added, 0 means it's unlimited
+	MaxSize bytesize.Size
+
+	// mu stands in for the lock file where file locks aren't available
+	mu sync.RWMutex
+}
+
+// lockName is the name of the lock file in the cache directory,
+// it starts with a dot so that it isn't taken for an entry
+const lockName = ".lock"
+
+// New returns a Cache in @dir limited to @maxSize bytes
+func New(dir string, maxSize bytesize.Size) *Cache {
+	return &Cache{Dir: dir, MaxSize: maxSize}
+}
+
+// Entry is a file stored in the cache
+type Entry struct {
+	Path string
+	Size int64
+	// Used is when the entry was added or last taken out of the cache
+	Used time.Time
+}
+
+// key returns the name of the entry holding the file
+// downloaded from @url with @checksum
+func key(url, checksum string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + checksum))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) entryPath(url, checksum string) string {
+	k := key(url, checksum)
+	return filepath.Join(c.Dir, k[:2], k)
+}
+
+// Get places the file downloaded from @url with @checksum at @path
+// returning whether it was in the cache
+func (c *Cache) Get(url, checksum, path string) (bool, error) {
+	unlock, err := c.lock(false)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	entry := c.entryPath(url, checksum)
+	if _, err := os.Stat(entry); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := place(entry, path); err != nil {
+		return false, err
+	}
+
+	// entries are evicted in the order they were last used
+	now := time.Now()
+	os.Chtimes(entry, now, now)
+	return true, nil
+}
+
+// Put adds the file at @path downloaded from @url with @checksum
+// to the cache, replacing a previous entry, and prunes the cache to MaxSize
+func (c *Cache) Put(url, checksum, path string) error {
+	entry := c.entryPath(url, checksum)
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		return fmt.Errorf("error creating directory %v, error : %v", filepath.Dir(entry), err)
+	}
+
+	unlock, err := c.lock(false)
+	if err != nil {
+		return err
+	}
+	err = place(path, entry)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	if c.MaxSize > 0 {
+		_, _, err := c.Prune(c.MaxSize)
+		return err
+	}
+	return nil
+}
+
+// Entries returns all the entries in the cache
+func (c *Cache) Entries() ([]Entry, error) {
+	var entries []Entry
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// temporary files start with a dot
+		if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+			entries = append(entries, Entry{Path: path, Size: info.Size(), Used: info.ModTime()})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Prune removes least recently used entries until the cache holds
+// at most @maxSize bytes. It returns the number of removed entries
+// and the number of bytes they took.
+func (c *Cache) Prune(maxSize bytesize.Size) (removed int, freed int64, err error) {
+	unlock, err := c.lock(true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Used.Before(entries[j].Used) })
+	for _, e := range entries {
+		if total <= int64(maxSize) {
+			break
+		}
+		// another process might have pruned it already
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return removed, freed, err
+		}
+		total -= e.Size
+		removed++
+		freed += e.Size
+	}
+	return removed, freed, nil
+}
+
+// place atomically puts the file at @src at @dst, hard linking
+// it when possible, and copying it otherwise e.g. across devices
+func place(src, dst string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file for %v, error : %v", dst, err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if err := os.Link(src, tmpPath); err != nil {
+		if err := copyFile(src, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("error copying %v to %v, error : %v", src, dst, err)
+		}
+	}
+
+	err = os.Rename(tmpPath, dst)
+	// renaming does nothing when both are links to the same file
+	os.Remove(tmpPath)
+	if err != nil {
+		return fmt.Errorf("error renaming %v, error : %v", tmpPath, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Cache_PutGet(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.deb")
+	writeFile(t, src, "hello world")
+
+	if err := c.Put("http://example.com/a.deb", "sha256:aaaa", src); err != nil {
+		t.Fatalf("Put() returned an unexpected error: %v", err)
+	}
+
+	dst := filepath.Join(dir, "b.deb")
+	if cached, err := c.Get("http://example.com/a.deb", "sha256:aaaa", dst); err != nil || !cached {
+		t.Fatalf("Get() was supposed to find the file but returned %v, %v", cached, err)
+	}
+	if data, _ := ioutil.ReadFile(dst); string(data) != "hello world" {
+		t.Errorf("Get()\nExpected: %q,\nactual %q", "hello world", data)
+	}
+
+	// placing the file over a link to the same file
+	if cached, err := c.Get("http://example.com/a.deb", "sha256:aaaa", src); err != nil || !cached {
+		t.Fatalf("Get() was supposed to find the file but returned %v, %v", cached, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, ".*")); len(files) != 0 {
+		t.Errorf("Get() left temporary files behind: %v", files)
+	}
+
+	tests := []struct {
+		url      string
+		checksum string
+	}{
+		{"http://example.com/b.deb", "sha256:aaaa"},
+		{"http://example.com/a.deb", "sha256:bbbb"},
+	}
+	for _, tt := range tests {
+		if cached, err := c.Get(tt.url, tt.checksum, filepath.Join(dir, "c.deb")); err != nil || cached {
+			t.Errorf("Get(%q, %q) was supposed to miss but returned %v, %v", tt.url, tt.checksum, cached, err)
+		}
+	}
+}
+
+func Test_Cache_Prune(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	dir := t.TempDir()
+
+	now := time.Now()
+	for i, name := range []string{"a", "b", "c"} {
+		src := filepath.Join(dir, name)
+		writeFile(t, src, "0123456789")
+		if err := c.Put(name, "", src); err != nil {
+			t.Fatal(err)
+		}
+		used := now.Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.entryPath(name, ""), used, used)
+	}
+
+	// getting a makes b the least recently used one
+	if _, err := c.Get("a", "", filepath.Join(dir, "a2")); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, freed, err := c.Prune(25)
+	if err != nil {
+		t.Fatalf("Prune() returned an unexpected error: %v", err)
+	}
+	if removed != 1 || freed != 10 {
+		t.Errorf("Prune()\nExpected: 1 removed, 10 freed,\nactual %d removed, %d freed", removed, freed)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		cached bool
+	}{{"a", true}, {"b", false}, {"c", true}} {
+		if cached, _ := c.Get(tt.name, "", filepath.Join(dir, "x")); cached != tt.cached {
+			t.Errorf("Get(%q) after Prune()\nExpected: %v,\nactual %v", tt.name, tt.cached, cached)
+		}
+	}
+
+	if removed, _, _ := c.Prune(0); removed != 2 {
+		t.Errorf("Prune(0) was supposed to remove the remaining 2 entries but removed %d", removed)
+	}
+}
+
+func Test_Cache_PutPrunesToMaxSize(t *testing.T) {
+	c := New(t.TempDir(), 15)
+	dir := t.TempDir()
+
+	for _, name := range []string{"a", "b"} {
+		src := filepath.Join(dir, name)
+		writeFile(t, src, "0123456789")
+		if err := c.Put(name, "", src); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Put() was supposed to keep the cache at 15 bytes but it holds %d entries", len(entries))
+	}
+}
+
+func Test_Cache_EntriesMissingDir(t *testing.T) {
+	entries, err := New(filepath.Join(t.TempDir(), "missing"), 0).Entries()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Entries() of a missing directory\nExpected: no entries,\nactual %v, %v", entries, err)
+	}
+}
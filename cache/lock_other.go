@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package cache
+
+// lock takes a shared, or @exclusive, lock on the cache. Without
+// flock it only keeps goroutines of this process from pruning while
+// others get or put files. It returns the function releasing it.
+func (c *Cache) lock(exclusive bool) (func(), error) {
+	if exclusive {
+		c.mu.Lock()
+		return c.mu.Unlock, nil
+	}
+	c.mu.RLock()
+	return c.mu.RUnlock, nil
+}
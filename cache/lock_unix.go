@@ -0,0 +1,44 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lock takes a shared, or @exclusive, flock on the cache directory's
+// lock file, waiting for other processes to release theirs. It returns
+// the function releasing it.
+func (c *Cache) lock(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating directory %v, error : %v", c.Dir, err)
+	}
+
+	path := filepath.Join(c.Dir, lockName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file %v, error : %v", path, err)
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error locking %v, error : %v", path, err)
+	}
+
+	// closing the file releases the lock
+	return func() { f.Close() }, nil
+}
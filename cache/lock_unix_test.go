@@ -0,0 +1,42 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Cache_LockAcrossInstances(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "a.deb")
+	writeFile(t, src, "hello world")
+
+	// another process pruning the same directory
+	unlock, err := New(dir, 0).lock(true)
+	if err != nil {
+		t.Fatalf("lock() returned an unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- New(dir, 0).Put("http://example.com/a.deb", "sha256:aaaa", src)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Put() was supposed to wait for the exclusive lock but returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	if err := <-done; err != nil {
+		t.Errorf("Put() returned an unexpected error: %v", err)
+	}
+
+	if entries, err := New(dir, 0).Entries(); err != nil || len(entries) != 1 {
+		t.Errorf("Entries() was supposed to return the entry only, actual %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/pmalek/kernel_deb_downloader/system"
+	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// Exit codes of the check subcommand
+const (
+	checkUpToDate        = 0
+	checkError           = 1
+	checkUpdateAvailable = 100
+)
+
+// runCheck implements the check subcommand which compares the running
+// kernel with the release selected by the global flags from @channel,
+// built for @archs. It returns checkUpToDate, checkUpdateAvailable,
+// checkError or exitInterrupted as the process exit code.
+func runCheck(ctx context.Context, args []string, channel ubuntukernelpageutils.Channel, archs []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	root := fs.String("root", "/", "Root of the system whose running kernel is checked")
+	// -h doesn't check anything so it isn't reported as up to date
+	if err := fs.Parse(args); err != nil {
+		return checkError
+	}
+
+	running, err := system.KernelRelease(*root)
+	if err != nil {
+		fmt.Println(err)
+		return checkError
+	}
+	runningVersion, err := versionutils.ParseKernelRelease(running)
+	if err != nil {
+		fmt.Println(err)
+		return checkError
+	}
+
+	version, packageURL, err := resolveVersion(ctx, channel, archs)
+	if err != nil {
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+		fmt.Printf("Error resolving kernel version from Ubuntu's kernel ppa webpage, error: %q\n", err)
+		return checkError
+	}
+
+	fmt.Printf("Running kernel: %s (%v)\n", running, runningVersion)
+	if runningVersion.Less(version) {
+		fmt.Printf("Update available: %v, link: %v\n", version, packageURL)
+		return checkUpdateAvailable
+	}
+
+	fmt.Printf("Up to date, newest matching version: %v\n", version)
+	return checkUpToDate
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/dpkg"
+	"github.com/pmalek/kernel_deb_downloader/system"
+	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// runClean implements the clean subcommand which removes installed mainline
+// kernels except the newest ones, the running one and the pinned ones.
+// It returns the process exit code.
+func runClean(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	keep := fs.Int("keep", 2, "Number of newest mainline releases to keep, the running and pinned ones are kept on top of them")
+	pins := fs.String("pin", "", "Comma separated releases to keep e.g. \"v6.1.80,v6.6.20\"")
+	dryRun := fs.Bool("dry-run", false, "Print the dpkg commands instead of running them")
+	sudo := fs.Bool("sudo", os.Geteuid() != 0, "Run dpkg with sudo")
+	root := fs.String("root", "/", "Root of the system whose dpkg database and running kernel are read")
+	fs.Parse(args)
+
+	if *keep < 0 {
+		fmt.Println("-keep can't be negative")
+		return 2
+	}
+
+	policy := ubuntukernelpageutils.CleanupPolicy{Keep: *keep}
+	for _, pin := range strings.Split(*pins, ",") {
+		if pin = strings.TrimSpace(pin); pin == "" {
+			continue
+		}
+		v, err := versionutils.Parse(pin)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		policy.Pinned = append(policy.Pinned, v)
+	}
+
+	// the running kernel has to be known to never remove it
+	running, err := system.KernelRelease(*root)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	policy.Running = running
+
+	status, err := dpkg.ReadStatus(*root)
+	if err != nil {
+		fmt.Printf("Error reading dpkg status: %v\n", err)
+		return 1
+	}
+
+	var packages []ubuntukernelpageutils.Package
+	for _, p := range status {
+		if !p.Installed() {
+			continue
+		}
+		if pkg, ok := ubuntukernelpageutils.ParsePackageFileName(fmt.Sprintf("%s_%s_%s.deb", p.Name, p.Version, p.Architecture)); ok {
+			packages = append(packages, pkg)
+		}
+	}
+
+	remove, kept := ubuntukernelpageutils.SelectRemovable(ubuntukernelpageutils.GroupInstalled(packages), policy)
+	for _, r := range kept {
+		fmt.Printf("Keeping %v (%s)\n", r.Version, r.Release)
+	}
+	if len(remove) == 0 {
+		fmt.Println("Nothing to remove")
+		return 0
+	}
+
+	var runner dpkg.Runner = dpkg.ExecRunner{Stdout: os.Stdout, Stderr: os.Stderr}
+	if *dryRun {
+		runner = dpkg.DryRunner{W: os.Stdout}
+	}
+
+	failed := false
+	for _, r := range remove {
+		// stop between releases, dpkg isn't killed by SIGINT or SIGTERM
+		// which would leave packages half removed
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted")
+			return exitInterrupted
+		}
+
+		names := make([]string, 0, len(r.Packages))
+		for _, p := range r.Packages {
+			names = append(names, p.Name)
+		}
+
+		if err := dpkg.Purge(context.Background(), runner, names, dpkg.Options{Sudo: *sudo}); err != nil {
+			fmt.Printf("Error removing %v: %v\n", r.Version, err)
+			failed = true
+		} else if !*dryRun {
+			fmt.Printf("Removed %v (%s)\n", r.Version, strings.Join(names, ", "))
+		}
+	}
+
+	exitIfInterrupted(ctx)
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package deb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// arReader reads members of an ar archive, the container of .debs
+type arReader struct {
+	r io.Reader
+	// remaining is the number of unread bytes of the current member
+	remaining int64
+	// padded is whether the current member is followed by a padding byte
+	padded bool
+}
+
+// newArReader checks the ar magic of @r and returns a reader of its members
+func newArReader(r io.Reader) (*arReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != arMagic {
+		return nil, errors.New("not an ar archive")
+	}
+	return &arReader{r: br}, nil
+}
+
+// next skips the rest of the current member and returns
+// the name and size of the next one, io.EOF at the end
+func (a *arReader) next() (string, int64, error) {
+	skip := a.remaining
+	if a.padded {
+		skip++
+	}
+	if _, err := io.CopyN(ioutil.Discard, a.r, skip); err != nil {
+		return "", 0, fmt.Errorf("truncated ar archive: %v", err)
+	}
+	a.remaining, a.padded = 0, false
+
+	header := make([]byte, arHeaderSize)
+	if _, err := io.ReadFull(a.r, header); err == io.EOF {
+		return "", 0, io.EOF
+	} else if err != nil {
+		return "", 0, fmt.Errorf("truncated ar header: %v", err)
+	}
+	if string(header[58:60]) != "`\n" {
+		return "", 0, errors.New("invalid ar header")
+	}
+
+	// GNU ar terminates names with a slash
+	name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+	size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("invalid size of ar member %s", name)
+	}
+
+	a.remaining, a.padded = size, size%2 == 1
+	return name, size, nil
+}
+
+// Read reads from the current member
+func (a *arReader) Read(p []byte) (int, error) {
+	if a.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > a.remaining {
+		p = p[:a.remaining]
+	}
+
+	n, err := a.r.Read(p)
+	a.remaining -= int64(n)
+	if err == io.EOF && a.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
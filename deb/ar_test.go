@@ -0,0 +1,92 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// arMember is a member of an ar archive built by buildAr
+type arMember struct {
+	name string
+	data []byte
+}
+
+// buildAr builds an ar archive out of @members the way GNU ar does
+func buildAr(members ...arMember) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, m := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name+"/", 0, 0, 0, "100644", len(m.data))
+		buf.Write(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func Test_arReader(t *testing.T) {
+	archive := buildAr(
+		arMember{"debian-binary", []byte("2.0\n")},
+		arMember{"odd", []byte("abc")},
+		arMember{"skipped", []byte("not read")},
+		arMember{"last", []byte("the end")},
+	)
+
+	ar, err := newArReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("newArReader() returned an unexpected error: %v", err)
+	}
+
+	for _, expected := range []struct {
+		name string
+		data string
+		read bool
+	}{
+		{"debian-binary", "2.0\n", true},
+		{"odd", "abc", true},
+		{"skipped", "not read", false},
+		{"last", "the end", true},
+	} {
+		name, size, err := ar.next()
+		if err != nil {
+			t.Fatalf("next() returned an unexpected error: %v", err)
+		}
+		if name != expected.name || size != int64(len(expected.data)) {
+			t.Errorf("next()\nExpected: %v %v,\nactual %v %v", expected.name, len(expected.data), name, size)
+		}
+		if !expected.read {
+			continue
+		}
+		data, err := ioutil.ReadAll(ar)
+		if err != nil || string(data) != expected.data {
+			t.Errorf("reading %s\nExpected: %q,\nactual %q (%v)", name, expected.data, data, err)
+		}
+	}
+
+	if _, _, err := ar.next(); err != io.EOF {
+		t.Errorf("next() at the end\nExpected: %v,\nactual %v", io.EOF, err)
+	}
+}
+
+func Test_arReader_Invalid(t *testing.T) {
+	if _, err := newArReader(strings.NewReader("PK\x03\x04")); err == nil {
+		t.Errorf("newArReader() was supposed to return an error for a non ar archive")
+	}
+
+	archive := buildAr(arMember{"data.tar", bytes.Repeat([]byte("x"), 100)})
+	ar, err := newArReader(bytes.NewReader(archive[:len(archive)-10]))
+	if err != nil {
+		t.Fatalf("newArReader() returned an unexpected error: %v", err)
+	}
+	if _, _, err := ar.next(); err != nil {
+		t.Fatalf("next() returned an unexpected error: %v", err)
+	}
+	if _, err := ioutil.ReadAll(ar); err != io.ErrUnexpectedEOF {
+		t.Errorf("reading a truncated member\nExpected: %v,\nactual %v", io.ErrUnexpectedEOF, err)
+	}
+}
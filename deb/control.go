@@ -0,0 +1,94 @@
+package deb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Control holds the fields of a package's control file
+type Control struct {
+	Package      string
+	Version      string
+	Architecture string
+	// InstalledSize is the estimated size of the installed files in KiB
+	InstalledSize int64
+	Depends       string
+	// Fields holds all the fields, multiline ones joined with newlines
+	Fields map[string]string
+}
+
+// ParseControl parses a control file from @r, it holds "Field: value"
+// lines continued by lines starting with a space
+func ParseControl(r io.Reader) (Control, error) {
+	c := Control{Fields: map[string]string{}}
+
+	var field string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			if field == "" {
+				return Control{}, fmt.Errorf("continuation line %q without a field", line)
+			}
+			c.Fields[field] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return Control{}, fmt.Errorf("invalid control line %q", line)
+		}
+		field = line[:colon]
+		c.Fields[field] = strings.TrimSpace(line[colon+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return Control{}, err
+	}
+
+	c.Package = c.Fields["Package"]
+	c.Version = c.Fields["Version"]
+	c.Architecture = c.Fields["Architecture"]
+	c.Depends = c.Fields["Depends"]
+	if size, ok := c.Fields["Installed-Size"]; ok {
+		var err error
+		if c.InstalledSize, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return Control{}, fmt.Errorf("invalid Installed-Size %q", size)
+		}
+	}
+
+	if c.Package == "" || c.Version == "" || c.Architecture == "" {
+		return Control{}, fmt.Errorf("control file lacks Package, Version or Architecture")
+	}
+	return c, nil
+}
+
+// parseMD5Sums parses an md5sums file holding "<md5>  <path>" lines
+// returning the sums keyed by paths without the leading ./ or /
+func parseMD5Sums(r io.Reader) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 || len(fields[0]) != 32 {
+			return nil, fmt.Errorf("invalid md5sums line %q", line)
+		}
+		sums[cleanPath(strings.TrimSpace(fields[1]))] = strings.ToLower(fields[0])
+	}
+	return sums, scanner.Err()
+}
+
+// cleanPath strips the leading ./ or / of paths in .deb archives
+func cleanPath(path string) string {
+	return strings.TrimLeft(strings.TrimPrefix(path, "./"), "/")
+}
@@ -0,0 +1,77 @@
+package deb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const controlTestData = `Package: linux-image-unsigned-6.8.2-060802-generic
+Source: linux-upstream
+Version: 6.8.2-060802.202403271436
+Architecture: amd64
+Maintainer: Kernel Team <kernel-team@lists.ubuntu.com>
+Installed-Size: 14563
+Depends: kmod, linux-base (>= 4.5ubuntu1~16.04.1), linux-modules-6.8.2-060802-generic
+Section: kernel
+Priority: optional
+Description: Linux kernel image for version 6.8.2 on 64 bit x86 SMP
+ This package contains the unsigned Linux kernel image for version 6.8.2.
+`
+
+func Test_ParseControl(t *testing.T) {
+	c, err := ParseControl(strings.NewReader(controlTestData))
+	if err != nil {
+		t.Fatalf("ParseControl() returned an unexpected error: %v", err)
+	}
+
+	if c.Package != "linux-image-unsigned-6.8.2-060802-generic" ||
+		c.Version != "6.8.2-060802.202403271436" ||
+		c.Architecture != "amd64" ||
+		c.InstalledSize != 14563 ||
+		c.Depends != "kmod, linux-base (>= 4.5ubuntu1~16.04.1), linux-modules-6.8.2-060802-generic" {
+		t.Errorf("ParseControl() returned unexpected fields %+v", c)
+	}
+
+	expected := "Linux kernel image for version 6.8.2 on 64 bit x86 SMP\nThis package contains the unsigned Linux kernel image for version 6.8.2."
+	if c.Fields["Description"] != expected {
+		t.Errorf("ParseControl() Description\nExpected: %q,\nactual %q", expected, c.Fields["Description"])
+	}
+}
+
+func Test_ParseControl_Invalid(t *testing.T) {
+	tests := []string{
+		"Package: a\nVersion: 1\n",
+		"Package: a\nVersion: 1\nArchitecture: amd64\nInstalled-Size: big\n",
+		" continued\n",
+		"Package a\n",
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseControl(strings.NewReader(tt)); err == nil {
+			t.Errorf("ParseControl(%q) was supposed to return an error", tt)
+		}
+	}
+}
+
+func Test_parseMD5Sums(t *testing.T) {
+	sums, err := parseMD5Sums(strings.NewReader(
+		"d41d8cd98f00b204e9800998ecf8427e  boot/vmlinuz-6.8.2-060802-generic\n" +
+			"\n" +
+			"0CC175B9C0F1B6A831C399E269772661  ./usr/share/doc/linux image/copyright\n"))
+	if err != nil {
+		t.Fatalf("parseMD5Sums() returned an unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"boot/vmlinuz-6.8.2-060802-generic":   "d41d8cd98f00b204e9800998ecf8427e",
+		"usr/share/doc/linux image/copyright": "0cc175b9c0f1b6a831c399e269772661",
+	}
+	if !reflect.DeepEqual(sums, expected) {
+		t.Errorf("parseMD5Sums()\nExpected: %v,\nactual %v", expected, sums)
+	}
+
+	if _, err := parseMD5Sums(strings.NewReader("abc  file\n")); err == nil {
+		t.Errorf("parseMD5Sums() was supposed to return an error for an invalid sum")
+	}
+}
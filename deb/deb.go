@@ -0,0 +1,279 @@
+// Package deb reads .deb packages without dpkg: the ar container, the
+// control file and the payload, with tarballs compressed with gzip, xz,
+// zstd, bzip2 or not at all
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Info describes a .deb
+type Info struct {
+	Control Control
+	// MD5Sums is whether the .deb has an md5sums file to verify its payload against
+	MD5Sums bool
+	// Files is the number of regular files in the payload
+	Files int
+	// Mismatched lists payload files whose md5 sum doesn't match md5sums
+	Mismatched []string
+	// Missing lists files in md5sums which aren't in the payload
+	Missing []string
+}
+
+// Valid returns whether the payload matches md5sums
+func (i *Info) Valid() bool {
+	return len(i.Mismatched) == 0 && len(i.Missing) == 0
+}
+
+// InspectFile inspects the .deb at @path, see Inspect
+func InspectFile(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := Inspect(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return info, nil
+}
+
+// Inspect reads the .deb from @r parsing its control file and verifying
+// each file of its payload against md5sums. An error is returned when
+// the .deb is malformed, mismatching files are reported in Info.
+func Inspect(r io.Reader) (*Info, error) {
+	ar, err := openDeb(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		info    Info
+		sums    map[string]string
+		control bool
+		data    bool
+	)
+	for {
+		name, _, err := ar.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case strings.HasPrefix(name, "control.tar"):
+			if info.Control, sums, err = readControl(name, ar); err != nil {
+				return nil, err
+			}
+			info.MD5Sums = sums != nil
+			control = true
+		case strings.HasPrefix(name, "data.tar"):
+			if !control {
+				return nil, errors.New("data member precedes the control member")
+			}
+			if err := verifyData(name, ar, sums, &info); err != nil {
+				return nil, err
+			}
+			data = true
+		}
+	}
+
+	if !control || !data {
+		return nil, errors.New("control or data member is missing")
+	}
+	return &info, nil
+}
+
+// ReadControlFile reads the control file of the .deb at @path, see ReadControl
+func ReadControlFile(path string) (Control, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Control{}, err
+	}
+	defer f.Close()
+
+	c, err := ReadControl(f)
+	if err != nil {
+		return Control{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// ReadControl reads the control file of the .deb from @r, unlike
+// Inspect it stops before the payload so it's cheap even for big .debs
+func ReadControl(r io.Reader) (Control, error) {
+	ar, err := openDeb(r)
+	if err != nil {
+		return Control{}, err
+	}
+
+	for {
+		name, _, err := ar.next()
+		if err == io.EOF {
+			return Control{}, errors.New("control member is missing")
+		} else if err != nil {
+			return Control{}, err
+		}
+
+		if strings.HasPrefix(name, "control.tar") {
+			c, _, err := readControl(name, ar)
+			return c, err
+		}
+	}
+}
+
+// openDeb checks @r is a .deb of a supported format version and
+// returns a reader of its members positioned after debian-binary
+func openDeb(r io.Reader) (*arReader, error) {
+	ar, err := newArReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _, err := ar.next()
+	if err != nil || name != "debian-binary" {
+		return nil, errors.New("debian-binary is not the first member")
+	}
+	version, err := ioutil.ReadAll(io.LimitReader(ar, 16))
+	if err != nil || !bytes.HasPrefix(version, []byte("2.")) {
+		return nil, fmt.Errorf("unsupported .deb format version %q", bytes.TrimSpace(version))
+	}
+	return ar, nil
+}
+
+// decompress returns a reader of the ar member @name
+// decompressed according to its extension
+func decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	switch path.Ext(name) {
+	case ".tar":
+		return ioutil.NopCloser(r), nil
+	case ".gz":
+		return gzip.NewReader(r)
+	case ".xz":
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case ".zst":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case ".bz2":
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression of %s", name)
+	}
+}
+
+// readControl reads the control and md5sums files from
+// the control tarball @name, md5sums is nil when it's missing
+func readControl(name string, r io.Reader) (Control, map[string]string, error) {
+	dr, err := decompress(name, r)
+	if err != nil {
+		return Control{}, nil, fmt.Errorf("Could not read %s: %v", name, err)
+	}
+	defer dr.Close()
+
+	var (
+		control Control
+		found   bool
+		md5sums map[string]string
+		tr      = tar.NewReader(dr)
+	)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Control{}, nil, fmt.Errorf("Could not read %s: %v", name, err)
+		}
+
+		switch cleanPath(header.Name) {
+		case "control":
+			if control, err = ParseControl(tr); err != nil {
+				return Control{}, nil, err
+			}
+			found = true
+		case "md5sums":
+			if md5sums, err = parseMD5Sums(tr); err != nil {
+				return Control{}, nil, err
+			}
+		}
+	}
+
+	if !found {
+		return Control{}, nil, fmt.Errorf("%s lacks the control file", name)
+	}
+	return control, md5sums, nil
+}
+
+// verifyData hashes every file of the data tarball @name
+// recording the ones which don't match @sums in @info
+func verifyData(name string, r io.Reader, sums map[string]string, info *Info) error {
+	dr, err := decompress(name, r)
+	if err != nil {
+		return fmt.Errorf("Could not read %s: %v", name, err)
+	}
+	defer dr.Close()
+
+	computed := map[string]string{}
+	tr := tar.NewReader(dr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Could not read %s: %v", name, err)
+		}
+
+		filePath := cleanPath(header.Name)
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			h := md5.New()
+			if _, err := io.Copy(h, tr); err != nil {
+				return fmt.Errorf("Could not read %s from %s: %v", filePath, name, err)
+			}
+			computed[filePath] = hex.EncodeToString(h.Sum(nil))
+			info.Files++
+		case tar.TypeLink:
+			// hard links share the contents of their target
+			computed[filePath] = computed[cleanPath(header.Linkname)]
+		default:
+			continue
+		}
+
+		if expected, ok := sums[filePath]; ok && expected != computed[filePath] {
+			info.Mismatched = append(info.Mismatched, filePath)
+		}
+	}
+
+	for filePath := range sums {
+		if _, ok := computed[filePath]; !ok {
+			info.Missing = append(info.Missing, filePath)
+		}
+	}
+	sort.Strings(info.Missing)
+	return nil
+}
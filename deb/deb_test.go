@@ -0,0 +1,278 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const testControl = `Package: linux-modules-6.8.2-060802-generic
+Version: 6.8.2-060802.202403271436
+Architecture: amd64
+Installed-Size: 120
+Depends: linux-image-unsigned-6.8.2-060802-generic | linux-image-6.8.2-060802-generic
+`
+
+// tarFile is a file of a tarball built by buildTar
+type tarFile struct {
+	name string
+	data string
+	// link makes the file a hard link to the named one
+	link string
+}
+
+func buildTar(t *testing.T, files ...tarFile) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}
+		if f.link != "" {
+			header.Typeflag, header.Linkname, header.Size = tar.TypeLink, f.link, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// compress compresses @data the way the extension @ext says
+func compress(t *testing.T, ext string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch ext {
+	case "":
+		return data
+	case ".gz":
+		w = gzip.NewWriter(&buf)
+	case ".xz":
+		w, err = xz.NewWriter(&buf)
+	case ".zst":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("unsupported extension %s", ext)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func md5Hex(data string) string {
+	sum := md5.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// buildDeb builds a .deb with tarballs compressed as @ext, holding
+// testControl, md5sums of @sums and the payload of @files
+func buildDeb(t *testing.T, ext string, sums map[string]string, files ...tarFile) []byte {
+	control := []tarFile{{name: "./control", data: testControl}}
+	if sums != nil {
+		var md5sums string
+		for name, sum := range sums {
+			md5sums += fmt.Sprintf("%s  %s\n", sum, name)
+		}
+		control = append(control, tarFile{name: "./md5sums", data: md5sums})
+	}
+
+	return buildAr(
+		arMember{"debian-binary", []byte("2.0\n")},
+		arMember{"control.tar" + ext, compress(t, ext, buildTar(t, control...))},
+		arMember{"data.tar" + ext, compress(t, ext, buildTar(t, files...))},
+	)
+}
+
+func Test_Inspect(t *testing.T) {
+	files := []tarFile{
+		{name: "./lib/modules/6.8.2-060802-generic/kernel/a.ko", data: "module a"},
+		{name: "./lib/modules/6.8.2-060802-generic/kernel/b.ko", data: "module b"},
+		{name: "./lib/modules/6.8.2-060802-generic/kernel/c.ko", link: "./lib/modules/6.8.2-060802-generic/kernel/a.ko"},
+	}
+	sums := map[string]string{
+		"lib/modules/6.8.2-060802-generic/kernel/a.ko": md5Hex("module a"),
+		"lib/modules/6.8.2-060802-generic/kernel/b.ko": md5Hex("module b"),
+		"lib/modules/6.8.2-060802-generic/kernel/c.ko": md5Hex("module a"),
+	}
+
+	for _, ext := range []string{"", ".gz", ".xz", ".zst"} {
+		info, err := Inspect(bytes.NewReader(buildDeb(t, ext, sums, files...)))
+		if err != nil {
+			t.Errorf("Inspect() of a %q .deb returned an unexpected error: %v", ext, err)
+			continue
+		}
+
+		if info.Control.Package != "linux-modules-6.8.2-060802-generic" || info.Control.InstalledSize != 120 {
+			t.Errorf("Inspect() of a %q .deb returned unexpected control %+v", ext, info.Control)
+		}
+		if !info.MD5Sums || info.Files != 2 || !info.Valid() {
+			t.Errorf("Inspect() of a %q .deb was supposed to verify 2 files, actual %+v", ext, info)
+		}
+	}
+}
+
+func Test_Inspect_Mismatch(t *testing.T) {
+	sums := map[string]string{
+		"boot/vmlinuz":    md5Hex("kernel"),
+		"boot/System.map": md5Hex("symbols"),
+		"boot/config":     md5Hex("config"),
+	}
+	files := []tarFile{
+		{name: "./boot/vmlinuz", data: "corrupted kernel"},
+		{name: "./boot/System.map", data: "symbols"},
+		{name: "./usr/share/doc/changelog", data: "not in md5sums"},
+	}
+
+	info, err := Inspect(bytes.NewReader(buildDeb(t, ".gz", sums, files...)))
+	if err != nil {
+		t.Fatalf("Inspect() returned an unexpected error: %v", err)
+	}
+
+	expected := &Info{
+		Control:    info.Control,
+		MD5Sums:    true,
+		Files:      3,
+		Mismatched: []string{"boot/vmlinuz"},
+		Missing:    []string{"boot/config"},
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("Inspect()\nExpected: %+v,\nactual %+v", expected, info)
+	}
+	if info.Valid() {
+		t.Errorf("Valid() was supposed to be false for a corrupted payload")
+	}
+}
+
+func Test_Inspect_WithoutMD5Sums(t *testing.T) {
+	info, err := Inspect(bytes.NewReader(buildDeb(t, ".xz", nil, tarFile{name: "./boot/vmlinuz", data: "kernel"})))
+	if err != nil {
+		t.Fatalf("Inspect() returned an unexpected error: %v", err)
+	}
+	if info.MD5Sums || info.Files != 1 || !info.Valid() {
+		t.Errorf("Inspect() of a .deb without md5sums returned unexpected %+v", info)
+	}
+}
+
+func Test_Inspect_Invalid(t *testing.T) {
+	control := compress(t, ".gz", buildTar(t, tarFile{name: "./control", data: testControl}))
+	data := compress(t, ".gz", buildTar(t))
+
+	tests := []struct {
+		name    string
+		archive []byte
+	}{
+		{"not an ar archive", []byte("not a deb")},
+		{"unsupported version", buildAr(
+			arMember{"debian-binary", []byte("3.0\n")},
+			arMember{"control.tar.gz", control},
+			arMember{"data.tar.gz", data},
+		)},
+		{"debian-binary not first", buildAr(
+			arMember{"control.tar.gz", control},
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"data.tar.gz", data},
+		)},
+		{"data before control", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"data.tar.gz", data},
+			arMember{"control.tar.gz", control},
+		)},
+		{"missing data", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"control.tar.gz", control},
+		)},
+		{"unsupported compression", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"control.tar.lz4", control},
+			arMember{"data.tar.gz", data},
+		)},
+		{"missing control file", buildAr(
+			arMember{"debian-binary", []byte("2.0\n")},
+			arMember{"control.tar.gz", data},
+			arMember{"data.tar.gz", data},
+		)},
+	}
+
+	for _, tt := range tests {
+		if _, err := Inspect(bytes.NewReader(tt.archive)); err == nil {
+			t.Errorf("Inspect() was supposed to return an error for %s", tt.name)
+		}
+	}
+}
+
+func Test_InspectFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.deb")
+	if err := ioutil.WriteFile(path, buildDeb(t, ".zst", nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := InspectFile(path)
+	if err != nil {
+		t.Fatalf("InspectFile() returned an unexpected error: %v", err)
+	}
+	if info.Control.Version != "6.8.2-060802.202403271436" {
+		t.Errorf("InspectFile() returned unexpected control %+v", info.Control)
+	}
+
+	if _, err := InspectFile(filepath.Join(t.TempDir(), "missing.deb")); err == nil {
+		t.Errorf("InspectFile() was supposed to return an error for a missing file")
+	}
+}
+
+func Test_ReadControl(t *testing.T) {
+	// the payload isn't read so a corrupted one doesn't matter
+	archive := buildAr(
+		arMember{"debian-binary", []byte("2.0\n")},
+		arMember{"control.tar.xz", compress(t, ".xz", buildTar(t, tarFile{name: "./control", data: testControl}))},
+		arMember{"data.tar.xz", []byte("corrupted")},
+	)
+
+	c, err := ReadControl(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ReadControl() returned an unexpected error: %v", err)
+	}
+	if c.Package != "linux-modules-6.8.2-060802-generic" || c.Depends != "linux-image-unsigned-6.8.2-060802-generic | linux-image-6.8.2-060802-generic" {
+		t.Errorf("ReadControl() returned unexpected control %+v", c)
+	}
+
+	if _, err := ReadControl(bytes.NewReader(buildAr(arMember{"debian-binary", []byte("2.0\n")}))); err == nil {
+		t.Errorf("ReadControl() was supposed to return an error for a .deb without control")
+	}
+}
+
+func Test_ReadControlFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.deb")
+	if err := ioutil.WriteFile(path, buildDeb(t, ".gz", nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := ReadControlFile(path); err != nil || c.Version != "6.8.2-060802.202403271436" {
+		t.Errorf("ReadControlFile() returned %+v, error %v", c, err)
+	}
+	if _, err := ReadControlFile(filepath.Join(t.TempDir(), "missing.deb")); err == nil {
+		t.Errorf("ReadControlFile() was supposed to return an error for a missing file")
+	}
+}
@@ -0,0 +1,81 @@
+package download
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Supported checksum algorithms
+const (
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+)
+
+// Checksum is the expected digest of a downloaded file
+type Checksum struct {
+	// Algorithm is either SHA1 or SHA256
+	Algorithm string
+	// Sum is the hex encoded digest
+	Sum string
+}
+
+// ChecksumFromHex returns a Checksum of @sum, guessing
+// its algorithm from its length
+func ChecksumFromHex(sum string) (Checksum, error) {
+	if _, err := hex.DecodeString(sum); err != nil {
+		return Checksum{}, fmt.Errorf("invalid checksum %q: %v", sum, err)
+	}
+
+	switch len(sum) {
+	case sha1.Size * 2:
+		return Checksum{Algorithm: SHA1, Sum: strings.ToLower(sum)}, nil
+	case sha256.Size * 2:
+		return Checksum{Algorithm: SHA256, Sum: strings.ToLower(sum)}, nil
+	default:
+		return Checksum{}, fmt.Errorf("checksum %q is neither sha1 nor sha256", sum)
+	}
+}
+
+func (c Checksum) newHash() (hash.Hash, error) {
+	switch c.Algorithm {
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", c.Algorithm)
+	}
+}
+
+func (c Checksum) String() string {
+	return c.Algorithm + ":" + c.Sum
+}
+
+// ChecksumMismatchError is returned when a downloaded
+// file doesn't match its published checksum
+type ChecksumMismatchError struct {
+	FileName string
+	Expected Checksum
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum of %s doesn't match, expected %s, actual %s",
+		e.Expected.Algorithm, e.FileName, e.Expected.Sum, e.Actual)
+}
+
+// SizeMismatchError is returned when a downloaded file's size
+// doesn't match the size reported by the server
+type SizeMismatchError struct {
+	FileName string
+	Expected int64
+	Actual   int64
+}
+
+func (e *SizeMismatchError) Error() string {
+	return fmt.Sprintf("downloaded %d bytes of %s, expected %d", e.Actual, e.FileName, e.Expected)
+}
@@ -0,0 +1,36 @@
+package download
+
+import "testing"
+
+func Test_ChecksumFromHex(t *testing.T) {
+	tests := []struct {
+		sum       string
+		algorithm string
+		valid     bool
+	}{
+		{"2AAE6C35C94FCFB415DBE95F408B9CE91EE846ED", SHA1, true},
+		{"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", SHA256, true},
+		{"b94d27b9934d3e08", "", false},
+		{"zz4d27b9934d3e08a52e52d7da7dabfac484efe3", "", false},
+	}
+
+	for _, tt := range tests {
+		c, err := ChecksumFromHex(tt.sum)
+		if (err == nil) != tt.valid {
+			t.Errorf("ChecksumFromHex(%q)\nExpected valid: %t,\nactual error %v", tt.sum, tt.valid, err)
+			continue
+		}
+		if c.Algorithm != tt.algorithm {
+			t.Errorf("ChecksumFromHex(%q)\nExpected: %q,\nactual %q", tt.sum, tt.algorithm, c.Algorithm)
+		}
+	}
+}
+
+func Test_ChecksumMismatchError(t *testing.T) {
+	err := &ChecksumMismatchError{FileName: "a.deb", Expected: Checksum{Algorithm: SHA256, Sum: "aa"}, Actual: "bb"}
+
+	expected := "sha256 checksum of a.deb doesn't match, expected aa, actual bb"
+	if err.Error() != expected {
+		t.Errorf("ChecksumMismatchError.Error()\nExpected: %q,\nactual %q", expected, err.Error())
+	}
+}
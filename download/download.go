@@ -1,55 +1,81 @@
 package download
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/pmalek/kernel_deb_downloader/bytesize"
 	"github.com/pmalek/kernel_deb_downloader/http"
-	"github.com/pmalek/pb"
 )
 
 // ToWriter downloads contents from url using http client
-// and writes it into the io.Writer - out.
+// and writes it into the io.Writer - out, until ctx is done,
+// reporting received bytes to progress.
 // It returns number of bytes written and an error
-func ToWriter(client http.Getter, out io.Writer, url string, progressBar ...*pb.ProgressBar) (int64, error) {
-	tokens := strings.Split(url, "/")
-	fileName := tokens[len(tokens)-1]
-
-	resp, err := client.Get(url)
+func ToWriter(ctx context.Context, client http.Getter, out io.Writer, url string, progress ...FileProgress) (int64, error) {
+	resp, err := client.Get(ctx, url)
 	if err != nil {
-		return 0, fmt.Errorf("error downloading %v, error : %v", url, err)
+		return 0, fmt.Errorf("error downloading %v, error : %w", url, err)
 	}
 	defer resp.Body.Close()
 
-	if len(progressBar) > 1 {
-		return 0, fmt.Errorf("Passed in more than 1 progressBar")
+	if len(progress) > 1 {
+		return 0, fmt.Errorf("Passed in more than 1 progress")
 	}
 
-	var reader io.Reader = resp.Body
-	for _, p := range progressBar {
-		reader = p.NewProxyReader(resp.Body)
+	return copyBody(ctx, out, resp.Body, fileNameFromURL(url), nil, progress...)
+}
+
+// copyBody copies @body of the response downloading @fileName to @out
+// no faster than @limiters allow, @progress is reported the limited rate
+func copyBody(ctx context.Context, out io.Writer, body io.Reader, fileName string, limiters []*Limiter, progress ...FileProgress) (int64, error) {
+	var reader = LimitReader(ctx, body, limiters...)
+	for _, p := range progress {
+		reader = &progressReader{r: reader, p: p}
 	}
 
-	var n int64
-	if n, err = io.Copy(out, reader); err != nil {
-		return 0, fmt.Errorf("error copying to %v, error : %v", fileName, err)
+	n, err := io.Copy(out, reader)
+	if err != nil {
+		return n, &interruptedError{fileName: fileName, err: err}
 	}
 
 	return n, nil
 }
 
-func httpFileSizeWithHEAD(client http.Header, url string) (int64, error) {
-	resp, err := client.Head(url)
-	if err != nil {
-		return -1, fmt.Errorf("error HEADing %v, error : %v", url, err)
+// interruptedError is returned when receiving
+// a file fails after the download started
+type interruptedError struct {
+	fileName string
+	err      error
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("error copying to %v, error : %v", e.fileName, e.err)
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
+// resumable returns whether @err interrupted a download in
+// a way worth retrying, i.e. resuming it from its .part file
+func resumable(err error) bool {
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) {
+		return http.Retryable(interrupted.err)
 	}
-	defer resp.Body.Close()
 
-	return resp.ContentLength, nil
+	var sizeErr *SizeMismatchError
+	return errors.As(err, &sizeErr) && sizeErr.Actual < sizeErr.Expected
 }
 
 func fileNameFromURL(url string) string {
@@ -57,56 +83,303 @@ func fileNameFromURL(url string) string {
 	return tokens[len(tokens)-1]
 }
 
-// ToFiles downloads all the files from @urls in package
-// and puts the in the current directory
-func ToFiles(client http.GetterHeader, urls []string) []string {
-	filenames := make([]string, 0, len(urls))
+// Options configures ToFiles
+type Options struct {
+	// Dir is the directory files are downloaded to
+	Dir string
+	// Checksums maps file names to their expected checksums,
+	// when nil downloaded files aren't verified
+	Checksums map[string]Checksum
+	// Workers is the number of files downloaded at once,
+	// DefaultWorkers is used when it's below 1
+	Workers int
+	// Retry is the policy for retrying downloads interrupted
+	// while receiving a file, they're resumed when possible.
+	// Failing requests are retried by the client itself.
+	Retry http.RetryPolicy
+	// Cache, when set, is consulted before downloading files with
+	// a checksum and verified files are added to it
+	Cache Cache
+	// RateLimit is the number of bytes per second shared by all
+	// the files being downloaded, 0 means unlimited
+	RateLimit bytesize.Size
+	// FileRateLimit is the number of bytes per second
+	// each file is downloaded with, 0 means unlimited
+	FileRateLimit bytesize.Size
+	// Progress reports the progress of downloads, nothing is reported when it's nil
+	Progress Progress
+}
+
+// Cache keeps verified files across runs, checksums are given as Checksum.String()
+type Cache interface {
+	// Get places the file downloaded from url with checksum
+	// at path returning whether it was cached
+	Get(url, checksum, path string) (bool, error)
+	// Put adds the file at path downloaded from url with checksum
+	Put(url, checksum, path string) error
+}
 
-	pool, err := pb.StartPool()
-	if err != nil {
-		log.Fatal(err)
+// DefaultWorkers is the default number of files downloaded at once
+const DefaultWorkers = 4
+
+// Result describes the outcome of downloading a single file
+type Result struct {
+	URL string
+	// FileName is the path the file was downloaded to
+	FileName string
+	// Size is the number of bytes downloaded
+	Size int64
+	// Duration is how long downloading took, including retries
+	Duration time.Duration
+	// Checksum is the checksum the file was verified against
+	Checksum Checksum
+	// Verified is whether the file matched Checksum
+	Verified bool
+	// Cached is whether the file was taken from Options.Cache
+	Cached bool
+	Err    error
+}
+
+// Error aggregates errors of files which failed to download
+type Error struct {
+	// Failed holds results of the failed files
+	Failed []Result
+	// Total is the number of files that were downloaded
+	Total int
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		msgs = append(msgs, r.Err.Error())
+	}
+	return fmt.Sprintf("%d of %d files failed to download: %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+// resultsError returns an *Error for the failed @results or nil
+func resultsError(results []Result) error {
+	var failed []Result
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
 		return nil
 	}
+	return &Error{Failed: failed, Total: len(results)}
+}
+
+// ToFiles downloads all the files from @urls in package and puts them
+// in the directory @opts.Dir, creating it if needed. Files are verified
+// against @opts.Checksums while they're downloaded and removed on mismatch.
+// Files are downloaded into .part files first, which interrupted downloads
+// are resumed from when @client is an http.RangeGetter. Files found in
+// @opts.Cache matching their checksums aren't downloaded again.
+// It returns a result for each of @urls, in the same order, and an *Error
+// when any of them failed to download. Downloads stop once @ctx is done,
+// leaving incomplete files as .part files to resume from.
+func ToFiles(ctx context.Context, client http.GetterHeader, urls []string, opts Options) ([]Result, error) {
+	results := make([]Result, len(urls))
 
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating directory %v, error : %v", opts.Dir, err)
+	}
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = Silent
+	}
+	progress.Start(urls)
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	limiter := NewLimiter(int64(opts.RateLimit))
+
+	jobs := make(chan int)
 	var wg sync.WaitGroup
-	wg.Add(len(urls)) // Increment the WaitGroup counter.
-
-	for _, url := range urls {
-		go func(url string) { // Launch a goroutine to fetch the URL.
-			defer wg.Done() // Decrement the counter when the goroutine completes.
-
-			fileSize, err := httpFileSizeWithHEAD(client, url)
-			if err != nil {
-				log.Fatal(err)
-				return
-			} else if fileSize < 0 {
-				log.Fatalf("Requesting HEAD on %s return %d ContentLength", url, fileSize)
-				return
-			}
+	wg.Add(workers)
 
-			fileName := fileNameFromURL(url)
-			progressBar := pb.
-				New64(fileSize).
-				SetUnits(pb.U_BYTES).
-				Prefix(fmt.Sprintf("%-76s", fileName))
-			progressBar.ShowSpeed = true
-			pool.Add(progressBar)
-
-			file, err := os.Create(fileName)
-			if err != nil {
-				fmt.Printf("Error creating file %v, error : %v\n", fileName, err)
-				return
-			}
-			defer file.Close()
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
 
-			if _, err = ToWriter(client, file, url, progressBar); err != nil {
-				filenames = append(filenames, fileName)
+			for i := range jobs {
+				file := progress.File(urls[i])
+				start := time.Now()
+				results[i] = downloadFile(ctx, client, urls[i], opts, limiter, file)
+				results[i].Duration = time.Since(start)
+				file.Done(results[i])
 			}
-		}(url)
+		}()
+	}
+
+	for i := range urls {
+		jobs <- i
 	}
+	close(jobs)
 
-	defer pool.Stop()
 	wg.Wait() // Wait for all HTTP fetches to complete.
+	progress.Stop()
+
+	return results, resultsError(results)
+}
+
+// downloadFile downloads @url into @opts.Dir reporting its progress to @progress,
+// no faster than @limiter and @opts.FileRateLimit allow,
+// retrying interrupted downloads according to @opts.Retry
+func downloadFile(ctx context.Context, client http.GetterHeader, url string, opts Options, limiter *Limiter, progress FileProgress) Result {
+	fileName := fileNameFromURL(url)
+	path := filepath.Join(opts.Dir, fileName)
+
+	if result, ok := fromCache(url, path, opts); ok {
+		return result
+	}
+
+	remote, err := httpFileWithHEAD(ctx, client, url)
+	if err != nil {
+		return Result{URL: url, FileName: path, Err: err}
+	} else if remote.Size < 0 {
+		return Result{URL: url, FileName: path, Err: fmt.Errorf("Requesting HEAD on %s return %d ContentLength", url, remote.Size)}
+	}
+
+	progress.SetTotal(remote.Size)
+
+	limiters := []*Limiter{limiter, NewLimiter(int64(opts.FileRateLimit))}
+
+	var result Result
+	for attempt := 0; attempt < opts.Retry.Attempts(); attempt++ {
+		if attempt > 0 {
+			if err := http.Sleep(ctx, opts.Retry.Backoff(attempt-1, result.Err)); err != nil {
+				result.Err = err
+				break
+			}
+		}
+		if result = toFile(ctx, client, url, path, remote, opts.Checksums, limiters, progress); !resumable(result.Err) {
+			break
+		}
+	}
+
+	if result.Err == nil && result.Verified && opts.Cache != nil {
+		// failing to cache a file doesn't fail its download
+		opts.Cache.Put(url, result.Checksum.String(), path)
+	}
+	return result
+}
+
+// fromCache places the file from @url at @path when it's in
+// @opts.Cache and still matches its checksum from @opts.Checksums
+func fromCache(url, path string, opts Options) (Result, bool) {
+	if opts.Cache == nil || opts.Checksums == nil {
+		return Result{}, false
+	}
+
+	checksum, ok := opts.Checksums[filepath.Base(path)]
+	if !ok {
+		return Result{}, false
+	}
+	h, err := checksum.newHash()
+	if err != nil {
+		return Result{}, false
+	}
+
+	if cached, err := opts.Cache.Get(url, checksum.String(), path); err != nil || !cached {
+		return Result{}, false
+	}
+
+	// the cached file might have been corrupted, download it again then
+	if err := copyFileTo(h, path); err != nil || hex.EncodeToString(h.Sum(nil)) != checksum.Sum {
+		os.Remove(path)
+		return Result{}, false
+	}
 
-	return filenames
+	result := Result{URL: url, FileName: path, Checksum: checksum, Verified: true, Cached: true}
+	if info, err := os.Stat(path); err == nil {
+		result.Size = info.Size()
+	}
+	return result, true
+}
+
+// toFile downloads @remote at @url to @path verifying it against its entry
+// in @checksums (unless @checksums is nil). The file is downloaded into
+// a .part file, resuming a previous download if possible, which is renamed
+// to @path once its size and checksum are verified, so that a file at
+// @path is always complete. It's removed if it fails verification and kept
+// for resuming the download if downloading fails. It's received no faster
+// than @limiters allow, reporting its progress to @progress.
+func toFile(ctx context.Context, client http.Getter, url, path string, remote remoteFile, checksums map[string]Checksum, limiters []*Limiter, progress FileProgress) Result {
+	result := Result{URL: url, FileName: path}
+	fileName := filepath.Base(path)
+	partPath := path + partSuffix
+
+	var h hash.Hash
+	if checksums != nil {
+		checksum, ok := checksums[fileName]
+		if !ok {
+			result.Err = fmt.Errorf("no checksum is published for %s", fileName)
+			return result
+		}
+		if h, result.Err = checksum.newHash(); result.Err != nil {
+			return result
+		}
+		result.Checksum = checksum
+	}
+
+	file, resp, offset, err := openPart(ctx, client, url, partPath, remote)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	var out io.Writer = file
+	if h != nil {
+		if err := copyFileTo(h, partPath); err != nil {
+			file.Close()
+			result.Err = fmt.Errorf("error reading file %v, error : %v", partPath, err)
+			return result
+		}
+		out = io.MultiWriter(file, h)
+	}
+
+	progress.Set(offset)
+	n, err := copyBody(ctx, out, resp.Body, fileName, limiters, progress)
+	result.Size, result.Err = offset+n, err
+	if result.Err == nil {
+		result.Err = file.Sync()
+	}
+	if err := file.Close(); err != nil && result.Err == nil {
+		result.Err = fmt.Errorf("error writing file %v, error : %v", partPath, err)
+	}
+	if result.Err != nil {
+		return result
+	}
+
+	if remote.Size >= 0 && result.Size != remote.Size {
+		// a shorter file is resumed on the next attempt
+		if result.Size > remote.Size {
+			removePart(partPath)
+		}
+		result.Err = &SizeMismatchError{FileName: fileName, Expected: remote.Size, Actual: result.Size}
+		return result
+	}
+
+	if h != nil {
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != result.Checksum.Sum {
+			removePart(partPath)
+			result.Err = &ChecksumMismatchError{FileName: fileName, Expected: result.Checksum, Actual: actual}
+			return result
+		}
+		result.Verified = true
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		result.Err = fmt.Errorf("error renaming %v, error : %v", partPath, err)
+		return result
+	}
+	os.Remove(partPath + metaSuffix)
+	return result
 }
@@ -2,16 +2,23 @@ package download
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/quick"
 	"time"
 
+	"github.com/pmalek/kernel_deb_downloader/cache"
 	"github.com/pmalek/kernel_deb_downloader/http"
-	"github.com/pmalek/pb"
 )
 
 func Test_ToWriter_quick(t *testing.T) {
@@ -20,7 +27,7 @@ func Test_ToWriter_quick(t *testing.T) {
 		client.SetResponse(httpContent)
 
 		buff := &bytes.Buffer{}
-		n, err := ToWriter(client, buff, "")
+		n, err := ToWriter(context.Background(), client, buff, "")
 
 		if n != int64(buff.Len()) {
 			t.Errorf("Wrong number of bytes returned: %d, expected %d", n, buff.Len())
@@ -55,7 +62,7 @@ func Test_ToWriter_Error(t *testing.T) {
 	client.SetError(fmt.Errorf("Some error"))
 
 	buff := &bytes.Buffer{}
-	_, err := ToWriter(client, buff, "")
+	_, err := ToWriter(context.Background(), client, buff, "")
 
 	if err == nil {
 		t.Errorf("Error expected yet received nil error")
@@ -68,7 +75,7 @@ func Test_ToWriter_OneProgressBar(t *testing.T) {
 	client.SetResponse(expectedContent)
 
 	buff := &bytes.Buffer{}
-	_, err := ToWriter(client, buff, "", pb.New(0))
+	_, err := ToWriter(context.Background(), client, buff, "", Silent.File(""))
 
 	if err != nil {
 		t.Errorf("Error returned yet not expected, returned %q", err)
@@ -83,34 +90,7 @@ func Test_ToWriter_Error_ToManyProgressBars(t *testing.T) {
 	client := http.MockedClient{}
 
 	buff := &bytes.Buffer{}
-	_, err := ToWriter(client, buff, "", pb.New(0), pb.New(0))
-
-	if err == nil {
-		t.Errorf("Error expected yet received nil error")
-	}
-}
-
-func Test_httpFileSizeWithHead(t *testing.T) {
-	content := "Content"
-
-	client := http.MockedClient{}
-	client.SetResponse(content)
-
-	n, err := httpFileSizeWithHEAD(client, "")
-
-	if err != nil {
-		t.Errorf("Error returned yet not expected, returned %q", err)
-	}
-	if n != int64(len(content)) {
-		t.Errorf("Expected %d yet returned %d", len(content), n)
-	}
-}
-
-func Test_httpFileSizeWithHead_Error(t *testing.T) {
-	client := http.MockedClient{}
-	client.SetError(errors.New(""))
-
-	_, err := httpFileSizeWithHEAD(client, "")
+	_, err := ToWriter(context.Background(), client, buff, "", Silent.File(""), Silent.File(""))
 
 	if err == nil {
 		t.Errorf("Error expected yet received nil error")
@@ -144,3 +124,282 @@ func Test_fileNameFromURL(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// sha256 of "hello world"
+const helloWorldSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+func Test_toFile_Verified(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+
+	path := filepath.Join(t.TempDir(), "a.deb")
+	checksums := map[string]Checksum{"a.deb": {Algorithm: SHA256, Sum: helloWorldSHA256}}
+
+	result := toFile(context.Background(), client, "http://example.com/a.deb", path, remoteFile{Size: 11}, checksums, nil, Silent.File(""))
+	if result.Err != nil || !result.Verified || result.Size != 11 {
+		t.Errorf("toFile() was supposed to verify the file but returned %+v", result)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("toFile() didn't leave the downloaded file: %v", err)
+	}
+}
+
+func Test_toFile_ChecksumMismatch(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world!")
+
+	path := filepath.Join(t.TempDir(), "a.deb")
+	checksums := map[string]Checksum{"a.deb": {Algorithm: SHA256, Sum: helloWorldSHA256}}
+
+	result := toFile(context.Background(), client, "http://example.com/a.deb", path, remoteFile{Size: 12}, checksums, nil, Silent.File(""))
+	if _, ok := result.Err.(*ChecksumMismatchError); !ok || result.Verified {
+		t.Errorf("toFile() was supposed to return a *ChecksumMismatchError but returned %+v", result)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("toFile() was supposed to remove the file which failed verification")
+	}
+}
+
+func Test_toFile_ChecksumNotPublished(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+
+	path := filepath.Join(t.TempDir(), "a.deb")
+
+	result := toFile(context.Background(), client, "http://example.com/a.deb", path, remoteFile{Size: 11}, map[string]Checksum{}, nil, Silent.File(""))
+	if result.Err == nil {
+		t.Errorf("toFile() was supposed to return an error for a file without a checksum")
+	}
+
+	result = toFile(context.Background(), client, "http://example.com/a.deb", path, remoteFile{Size: 11}, nil, nil, Silent.File(""))
+	if result.Err != nil || result.Verified {
+		t.Errorf("toFile() was supposed to download the file without verifying it but returned %+v", result)
+	}
+}
+
+func Test_toFile_SizeMismatch(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello")
+
+	path := filepath.Join(t.TempDir(), "a.deb")
+
+	result := toFile(context.Background(), client, "http://example.com/a.deb", path, remoteFile{Size: 11}, nil, nil, Silent.File(""))
+	if _, ok := result.Err.(*SizeMismatchError); !ok {
+		t.Errorf("toFile() was supposed to return a *SizeMismatchError but returned %+v", result)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("toFile() was supposed to leave a truncated file only as %s", path+partSuffix)
+	}
+}
+
+// flakyClient serves content, failing the first failures
+// GETs midway and tracking the number of concurrent GETs
+type flakyClient struct {
+	http.MockedClient
+	content  string
+	failures int32
+	active   int32
+	peak     int32
+}
+
+type failingReader struct {
+	io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (c *flakyClient) Get(ctx context.Context, url string) (*nethttp.Response, error) {
+	active := atomic.AddInt32(&c.active, 1)
+	defer atomic.AddInt32(&c.active, -1)
+	for peak := atomic.LoadInt32(&c.peak); active > peak; peak = atomic.LoadInt32(&c.peak) {
+		if atomic.CompareAndSwapInt32(&c.peak, peak, active) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	var body io.Reader = strings.NewReader(c.content)
+	if atomic.AddInt32(&c.failures, -1) >= 0 {
+		body = failingReader{strings.NewReader(c.content[:3])}
+	}
+	return &nethttp.Response{StatusCode: 200, Body: ioutil.NopCloser(body)}, nil
+}
+
+func Test_ToFiles_Workers(t *testing.T) {
+	client := &flakyClient{content: "hello world"}
+	client.SetResponse(client.content)
+
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("http://example.com/%d.deb", i)
+	}
+
+	results, err := ToFiles(context.Background(), client, urls, Options{Dir: t.TempDir(), Workers: 3})
+	if err != nil {
+		t.Errorf("ToFiles() returned an unexpected error: %v", err)
+	}
+	for i, r := range results {
+		if r.URL != urls[i] || r.Size != int64(len(client.content)) || r.Duration <= 0 {
+			t.Errorf("ToFiles() returned an unexpected result for %s: %+v", urls[i], r)
+		}
+	}
+	if client.peak > 3 {
+		t.Errorf("ToFiles() was supposed to download at most 3 files at once but downloaded %d", client.peak)
+	}
+}
+
+func Test_ToFiles_RetriesInterrupted(t *testing.T) {
+	client := &flakyClient{content: "hello world", failures: 2}
+	client.SetResponse(client.content)
+
+	dir := t.TempDir()
+	retry := http.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	results, _ := ToFiles(context.Background(), client, []string{"http://example.com/a.deb"}, Options{Dir: dir, Retry: retry})
+	if results[0].Err != nil {
+		t.Fatalf("ToFiles() was supposed to succeed on the 3rd attempt but returned %v", results[0].Err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "a.deb")); string(data) != client.content {
+		t.Errorf("ToFiles()\nExpected: %q,\nactual %q", client.content, data)
+	}
+
+	client.failures = 2
+	results, _ = ToFiles(context.Background(), client, []string{"http://example.com/b.deb"}, Options{Dir: dir, Retry: http.RetryPolicy{MaxAttempts: 2}})
+	if !resumable(results[0].Err) {
+		t.Errorf("ToFiles() was supposed to give up after 2 attempts but returned %v", results[0].Err)
+	}
+}
+
+func Test_ToFiles_PartialFailure(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+	client.SetURLResponse("http://example.com/b.deb", "hello")
+
+	urls := []string{"http://example.com/a.deb", "http://example.com/b.deb", "http://example.com/c.deb"}
+	checksums := map[string]Checksum{
+		"a.deb": {Algorithm: SHA256, Sum: helloWorldSHA256},
+		"b.deb": {Algorithm: SHA256, Sum: helloWorldSHA256},
+	}
+
+	results, err := ToFiles(context.Background(), client, urls, Options{Dir: t.TempDir(), Checksums: checksums})
+
+	downloadErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("ToFiles() was supposed to return an *Error but returned %v", err)
+	}
+	if downloadErr.Total != 3 || len(downloadErr.Failed) != 2 ||
+		downloadErr.Failed[0].URL != urls[1] || downloadErr.Failed[1].URL != urls[2] {
+		t.Errorf("ToFiles() returned an unexpected error %+v", downloadErr)
+	}
+	if !results[0].Verified || results[0].Err != nil {
+		t.Errorf("ToFiles() was supposed to download %s but returned %+v", urls[0], results[0])
+	}
+	if _, ok := results[1].Err.(*ChecksumMismatchError); !ok {
+		t.Errorf("ToFiles() was supposed to return a *ChecksumMismatchError for %s but returned %v", urls[1], results[1].Err)
+	}
+}
+
+func Test_ToFiles_HEADError(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetStatusCode(404)
+
+	results, err := ToFiles(context.Background(), client, []string{"http://example.com/a.deb"}, Options{Dir: t.TempDir()})
+	if _, ok := results[0].Err.(*http.StatusError); !ok {
+		t.Errorf("ToFiles() was supposed to return a *http.StatusError but returned %v", results[0].Err)
+	}
+	if err == nil {
+		t.Errorf("ToFiles() was supposed to return an error but returned nil")
+	}
+}
+
+func Test_ToFiles_DirError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ToFiles(context.Background(), http.MockedClient{}, []string{"http://example.com/a.deb"}, Options{Dir: filepath.Join(file, "dir")}); err == nil {
+		t.Errorf("ToFiles() was supposed to return an error for a directory it can't create but returned nil")
+	}
+}
+
+func Test_Error(t *testing.T) {
+	err := &Error{Total: 3, Failed: []Result{{Err: errors.New("a failed")}, {Err: errors.New("b failed")}}}
+
+	expected := "2 of 3 files failed to download: a failed; b failed"
+	if err.Error() != expected {
+		t.Errorf("Error.Error()\nExpected: %q,\nactual %q", expected, err.Error())
+	}
+}
+
+func Test_ToFiles_Canceled(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := t.TempDir()
+	results, err := ToFiles(ctx, client, []string{"http://example.com/a.deb"}, Options{Dir: dir})
+	if !errors.Is(results[0].Err, context.Canceled) || err == nil {
+		t.Errorf("ToFiles() was supposed to fail with context.Canceled but returned %v, %v", results[0].Err, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.deb")); !os.IsNotExist(err) {
+		t.Errorf("ToFiles() wasn't supposed to create a.deb when canceled")
+	}
+}
+
+func Test_ToFiles_Cache(t *testing.T) {
+	c := cache.New(t.TempDir(), 0)
+	url := "http://example.com/a.deb"
+	checksums := map[string]Checksum{"a.deb": {Algorithm: SHA256, Sum: helloWorldSHA256}}
+
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+	results, err := ToFiles(context.Background(), client, []string{url}, Options{Dir: t.TempDir(), Checksums: checksums, Cache: c})
+	if err != nil || results[0].Cached {
+		t.Fatalf("ToFiles() was supposed to download %s but returned %+v, %v", url, results[0], err)
+	}
+
+	failing := http.MockedClient{}
+	failing.SetError(errors.New("Some error"))
+	dir := t.TempDir()
+	results, err = ToFiles(context.Background(), failing, []string{url}, Options{Dir: dir, Checksums: checksums, Cache: c})
+	if err != nil || !results[0].Cached || !results[0].Verified || results[0].Size != 11 {
+		t.Fatalf("ToFiles() was supposed to take %s from the cache but returned %+v, %v", url, results[0], err)
+	}
+
+	// corrupts the cached file which is linked to it
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.deb"), []byte("hello there"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir = t.TempDir()
+	results, err = ToFiles(context.Background(), client, []string{url}, Options{Dir: dir, Checksums: checksums, Cache: c})
+	if err != nil || results[0].Cached {
+		t.Fatalf("ToFiles() was supposed to download %s again but returned %+v, %v", url, results[0], err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dir, "a.deb")); string(data) != "hello world" {
+		t.Errorf("ToFiles()\nExpected: %q,\nactual %q", "hello world", data)
+	}
+}
+
+func Test_ToFiles_RateLimit(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(strings.Repeat("a", 2000))
+
+	urls := []string{"http://example.com/a.deb", "http://example.com/b.deb"}
+	start := time.Now()
+	if _, err := ToFiles(context.Background(), client, urls, Options{Dir: t.TempDir(), RateLimit: 10000}); err != nil {
+		t.Fatalf("ToFiles() returned an unexpected error: %v", err)
+	}
+	// 3000 bytes past the burst shared by both files at 10000 bytes per second
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("ToFiles() downloaded 4000 bytes in %v, expected at least 300ms", elapsed)
+	}
+}
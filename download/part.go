@@ -0,0 +1,181 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	"os"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+const (
+	// partSuffix is appended to names of files being downloaded
+	partSuffix = ".part"
+	// metaSuffix is appended to names of .part files
+	// to name the file describing what they contain
+	metaSuffix = ".meta"
+)
+
+// remoteFile describes a file as reported by a HEAD request
+type remoteFile struct {
+	Size int64
+	// Validator is the ETag or, when it's missing or weak, the
+	// Last-Modified date identifying the version of the file
+	Validator string
+}
+
+func httpFileWithHEAD(ctx context.Context, client http.Header, url string) (remoteFile, error) {
+	resp, err := client.Head(ctx, url)
+	if err != nil {
+		return remoteFile{Size: -1}, fmt.Errorf("error HEADing %v, error : %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if err := http.CheckStatus(resp, url); err != nil {
+		return remoteFile{Size: -1}, err
+	}
+
+	f := remoteFile{Size: resp.ContentLength}
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		f.Validator = etag
+	} else {
+		f.Validator = resp.Header.Get("Last-Modified")
+	}
+	return f, nil
+}
+
+// partMeta is stored next to a .part file so that it's
+// resumed only if the remote file didn't change since
+type partMeta struct {
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+	Validator string `json:"validator"`
+}
+
+// resumableOffset returns the size of the .part file at @partPath
+// when it can be resumed from, i.e. it holds the beginning of
+// @remote at @url, 0 otherwise
+func resumableOffset(url, partPath string, remote remoteFile) int64 {
+	if remote.Validator == "" {
+		return 0
+	}
+
+	data, err := ioutil.ReadFile(partPath + metaSuffix)
+	if err != nil {
+		return 0
+	}
+
+	var meta partMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0
+	}
+	if meta != (partMeta{URL: url, Size: remote.Size, Validator: remote.Validator}) {
+		return 0
+	}
+
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() >= remote.Size {
+		return 0
+	}
+	return info.Size()
+}
+
+// createPart creates an empty .part file at @partPath
+// recording what it's going to contain
+func createPart(url, partPath string, remote remoteFile) (*os.File, error) {
+	file, err := os.Create(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file %v, error : %v", partPath, err)
+	}
+
+	metaPath := partPath + metaSuffix
+	if remote.Validator == "" {
+		os.Remove(metaPath)
+		return file, nil
+	}
+
+	data, err := json.Marshal(partMeta{URL: url, Size: remote.Size, Validator: remote.Validator})
+	if err == nil {
+		err = ioutil.WriteFile(metaPath, data, 0644)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error creating file %v, error : %v", metaPath, err)
+	}
+	return file, nil
+}
+
+// removePart removes the .part file at @partPath along with its metadata
+func removePart(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partPath + metaSuffix)
+}
+
+// openPart opens the .part file at @partPath resuming the download of
+// @remote at @url with a ranged request when the file holds its beginning
+// and @client supports ranges, otherwise the download starts over.
+// It returns the file to append to, the response to copy
+// from and the offset the response body starts at.
+func openPart(ctx context.Context, client http.Getter, url, partPath string, remote remoteFile) (*os.File, *nethttp.Response, int64, error) {
+	offset := resumableOffset(url, partPath, remote)
+	ranger, ok := client.(http.RangeGetter)
+
+	var (
+		resp *nethttp.Response
+		err  error
+	)
+	if offset > 0 && ok {
+		resp, err = ranger.GetRange(ctx, url, offset, remote.Validator)
+	} else {
+		resp, err = client.Get(ctx, url)
+	}
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error downloading %v, error : %w", url, err)
+	}
+
+	if err := http.CheckStatus(resp, url); err != nil {
+		resp.Body.Close()
+		return nil, nil, 0, err
+	}
+
+	if offset > 0 && ok && resumed(resp, offset) {
+		file, err := os.OpenFile(partPath, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			resp.Body.Close()
+			return nil, nil, 0, fmt.Errorf("error opening file %v, error : %v", partPath, err)
+		}
+		return file, resp, offset, nil
+	}
+
+	// The server doesn't honour ranges or the file changed,
+	// resp holds the whole file so start over
+	file, err := createPart(url, partPath, remote)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, 0, err
+	}
+	return file, resp, 0, nil
+}
+
+// resumed returns whether @resp holds the remote file starting at @offset
+func resumed(resp *nethttp.Response, offset int64) bool {
+	return resp.StatusCode == nethttp.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset))
+}
+
+// copyFileTo writes contents of the file at @path to @w
+func copyFileTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
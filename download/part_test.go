@@ -0,0 +1,131 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+func Test_httpFileWithHEAD(t *testing.T) {
+	content := "Content"
+
+	client := http.MockedClient{}
+	client.SetResponse(content)
+	client.SetETag(`"abc"`)
+
+	f, err := httpFileWithHEAD(context.Background(), client, "")
+
+	if err != nil {
+		t.Errorf("Error returned yet not expected, returned %q", err)
+	}
+	if f.Size != int64(len(content)) {
+		t.Errorf("Expected %d yet returned %d", len(content), f.Size)
+	}
+	if f.Validator != `"abc"` {
+		t.Errorf("Expected validator %q yet returned %q", `"abc"`, f.Validator)
+	}
+}
+
+func Test_httpFileWithHEAD_Error(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetError(errors.New(""))
+
+	_, err := httpFileWithHEAD(context.Background(), client, "")
+
+	if err == nil {
+		t.Errorf("Error expected yet received nil error")
+	}
+}
+
+const (
+	partTestURL     = "http://example.com/a.deb"
+	partTestContent = "hello world"
+)
+
+// writePart leaves a partial download described by @meta in @dir,
+// returning the final path. It differs from partTestContent so that
+// it's visible whether the download was resumed.
+func writePart(t *testing.T, dir string, meta remoteFile) string {
+	path := filepath.Join(dir, "a.deb")
+	file, err := createPart(partTestURL, path+partSuffix, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("HELLO")
+	file.Close()
+	return path
+}
+
+func Test_toFile_Resume(t *testing.T) {
+	remote := remoteFile{Size: int64(len(partTestContent)), Validator: `"v1"`}
+
+	tests := []struct {
+		name         string
+		acceptRanges bool
+		etag         string
+		expected     string
+	}{
+		{"resumed", true, `"v1"`, "HELLO world"},
+		{"ranges not supported", false, `"v1"`, partTestContent},
+		{"file changed", true, `"v2"`, partTestContent},
+	}
+
+	for _, tt := range tests {
+		client := http.MockedClient{}
+		client.SetResponse(partTestContent)
+		client.SetAcceptRanges(tt.acceptRanges)
+		client.SetETag(tt.etag)
+
+		path := writePart(t, t.TempDir(), remote)
+
+		sum := sha256.Sum256([]byte(tt.expected))
+		checksums := map[string]Checksum{"a.deb": {Algorithm: SHA256, Sum: hex.EncodeToString(sum[:])}}
+
+		current := remoteFile{Size: remote.Size, Validator: tt.etag}
+		result := toFile(context.Background(), client, partTestURL, path, current, checksums, nil, Silent.File(""))
+		if result.Err != nil || !result.Verified || result.Size != int64(len(partTestContent)) {
+			t.Errorf("toFile() %s: unexpected result %+v", tt.name, result)
+			continue
+		}
+
+		if data, _ := ioutil.ReadFile(path); string(data) != tt.expected {
+			t.Errorf("toFile() %s\nExpected: %q,\nactual %q", tt.name, tt.expected, data)
+		}
+		for _, leftover := range []string{path + partSuffix, path + partSuffix + metaSuffix} {
+			if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+				t.Errorf("toFile() %s left %s behind", tt.name, leftover)
+			}
+		}
+	}
+}
+
+func Test_resumableOffset(t *testing.T) {
+	remote := remoteFile{Size: int64(len(partTestContent)), Validator: `"v1"`}
+	path := writePart(t, t.TempDir(), remote)
+
+	tests := []struct {
+		name     string
+		url      string
+		remote   remoteFile
+		expected int64
+	}{
+		{"same file", partTestURL, remote, 5},
+		{"different URL", "http://example.com/b.deb", remote, 0},
+		{"different validator", partTestURL, remoteFile{Size: remote.Size, Validator: `"v2"`}, 0},
+		{"different size", partTestURL, remoteFile{Size: 20, Validator: `"v1"`}, 0},
+		{"no validator", partTestURL, remoteFile{Size: remote.Size}, 0},
+	}
+
+	for _, tt := range tests {
+		if actual := resumableOffset(tt.url, path+partSuffix, tt.remote); actual != tt.expected {
+			t.Errorf("resumableOffset() %s\nExpected: %d,\nactual %d", tt.name, tt.expected, actual)
+		}
+	}
+}
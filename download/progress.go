@@ -0,0 +1,344 @@
+package download
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pmalek/pb"
+)
+
+// Progress reports the progress of downloads started by ToFiles
+type Progress interface {
+	// Start is called once before @urls are downloaded
+	Start(urls []string)
+	// File is called when downloading @url starts
+	File(url string) FileProgress
+	// Stop is called once all the files are downloaded
+	Stop()
+}
+
+// FileProgress reports the progress of downloading a single file
+type FileProgress interface {
+	// SetTotal reports the size of the file once it's known
+	SetTotal(size int64)
+	// Set reports that @n bytes of the file are downloaded
+	// e.g. when the download is resumed
+	Set(n int64)
+	// Add reports that @n more bytes were downloaded
+	Add(n int)
+	// Done reports that downloading the file finished with @result
+	Done(result Result)
+}
+
+// progressReader reports bytes read from r to p
+type progressReader struct {
+	r io.Reader
+	p FileProgress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.Add(n)
+	return n, err
+}
+
+// Silent is a Progress which doesn't report anything
+var Silent Progress = silentProgress{}
+
+type silentProgress struct{}
+
+func (silentProgress) Start([]string)           {}
+func (silentProgress) File(string) FileProgress { return silentProgress{} }
+func (silentProgress) Stop()                    {}
+func (silentProgress) SetTotal(int64)           {}
+func (silentProgress) Set(int64)                {}
+func (silentProgress) Add(int)                  {}
+func (silentProgress) Done(Result)              {}
+
+// barsProgress shows a progress bar of each file, it needs a terminal
+type barsProgress struct {
+	pool  *pb.Pool
+	width int
+}
+
+// NewBarsProgress returns a Progress drawing a progress bar of each file
+// on the terminal. Nothing is shown when the output isn't a terminal.
+func NewBarsProgress() Progress {
+	return &barsProgress{}
+}
+
+func (p *barsProgress) Start(urls []string) {
+	p.width = 0
+	for _, url := range urls {
+		if n := len(fileNameFromURL(url)); n > p.width {
+			p.width = n
+		}
+	}
+
+	pool, err := pb.StartPool()
+	if err != nil {
+		pool = nil
+	}
+	p.pool = pool
+}
+
+func (p *barsProgress) File(url string) FileProgress {
+	return &barFile{progress: p, name: fileNameFromURL(url)}
+}
+
+func (p *barsProgress) Stop() {
+	if p.pool != nil {
+		p.pool.Stop()
+	}
+}
+
+type barFile struct {
+	progress *barsProgress
+	name     string
+	bar      *pb.ProgressBar
+}
+
+// SetTotal adds the bar to the pool, it's drawn from then on
+func (f *barFile) SetTotal(size int64) {
+	if f.progress.pool == nil || f.bar != nil {
+		return
+	}
+
+	f.bar = pb.New64(size).SetUnits(pb.U_BYTES).Prefix(fmt.Sprintf("%-*s", f.progress.width, f.name))
+	f.bar.ShowSpeed = true
+	f.progress.pool.Add(f.bar)
+}
+
+func (f *barFile) Set(n int64) {
+	if f.bar != nil {
+		f.bar.Set64(n)
+	}
+}
+
+func (f *barFile) Add(n int) {
+	if f.bar != nil {
+		f.bar.Add(n)
+	}
+}
+
+func (f *barFile) Done(Result) {
+	if f.bar != nil {
+		f.bar.Finish()
+	}
+}
+
+// trackedFile is a file whose progress is reported periodically
+type trackedFile struct {
+	// downloaded and total are accessed atomically
+	downloaded int64
+	total      int64
+	url        string
+	name       string
+}
+
+func (f *trackedFile) Set(n int64) { atomic.StoreInt64(&f.downloaded, n) }
+func (f *trackedFile) Add(n int)   { atomic.AddInt64(&f.downloaded, int64(n)) }
+
+// event returns the Event @name describing f
+func (f *trackedFile) event(name string) Event {
+	return Event{
+		Event:      name,
+		URL:        f.url,
+		File:       f.name,
+		Size:       atomic.LoadInt64(&f.total),
+		Downloaded: atomic.LoadInt64(&f.downloaded),
+	}
+}
+
+// tracker calls report with each file being downloaded every interval
+type tracker struct {
+	interval time.Duration
+	report   func(f *trackedFile)
+
+	mu     sync.Mutex
+	active []*trackedFile
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func (t *tracker) start() {
+	if t.interval <= 0 {
+		return
+	}
+
+	t.stop, t.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(t.done)
+
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				t.mu.Lock()
+				for _, f := range t.active {
+					t.report(f)
+				}
+				t.mu.Unlock()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (t *tracker) add(f *trackedFile) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active = append(t.active, f)
+}
+
+func (t *tracker) remove(f *trackedFile) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, a := range t.active {
+		if a == f {
+			t.active = append(t.active[:i], t.active[i+1:]...)
+			return
+		}
+	}
+}
+
+func (t *tracker) finish() {
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+		t.stop = nil
+	}
+}
+
+// plainProgress logs a line when a file starts downloading
+// and its progress every interval, fit for CI logs
+type plainProgress struct {
+	tracker
+	writeMu sync.Mutex
+	w       io.Writer
+}
+
+// NewPlainProgress returns a Progress writing a line to @w when a file
+// starts downloading and the progress of each file every @interval
+func NewPlainProgress(w io.Writer, interval time.Duration) Progress {
+	p := &plainProgress{w: w}
+	p.tracker = tracker{interval: interval, report: p.reportProgress}
+	return p
+}
+
+func (p *plainProgress) println(format string, args ...interface{}) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
+
+func (p *plainProgress) reportProgress(f *trackedFile) {
+	downloaded, total := atomic.LoadInt64(&f.downloaded), atomic.LoadInt64(&f.total)
+	if total > 0 {
+		p.println("%s: %d%% (%d of %d bytes)", f.name, downloaded*100/total, downloaded, total)
+	} else {
+		p.println("%s: %d bytes", f.name, downloaded)
+	}
+}
+
+func (p *plainProgress) Start([]string) { p.start() }
+func (p *plainProgress) Stop()          { p.finish() }
+
+func (p *plainProgress) File(url string) FileProgress {
+	return &plainFile{trackedFile: trackedFile{url: url, name: fileNameFromURL(url)}, progress: p}
+}
+
+type plainFile struct {
+	trackedFile
+	progress *plainProgress
+}
+
+func (f *plainFile) SetTotal(size int64) {
+	atomic.StoreInt64(&f.total, size)
+	f.progress.println("Downloading %s (%d bytes)", f.name, size)
+	f.progress.add(&f.trackedFile)
+}
+
+func (f *plainFile) Done(Result) { f.progress.remove(&f.trackedFile) }
+
+// Event is a JSON line written by the Progress returned by NewJSONProgress
+type Event struct {
+	// Event is "start", "progress" or "done"
+	Event string `json:"event"`
+	URL   string `json:"url"`
+	File  string `json:"file"`
+	// Size is the size of the file, -1 when it's unknown
+	Size       int64  `json:"size"`
+	Downloaded int64  `json:"downloaded"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Checksum   string `json:"checksum,omitempty"`
+	Verified   bool   `json:"verified,omitempty"`
+	Cached     bool   `json:"cached,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// jsonProgress writes an Event per line
+type jsonProgress struct {
+	tracker
+	writeMu sync.Mutex
+	enc     *json.Encoder
+}
+
+// NewJSONProgress returns a Progress writing an Event per line to @w when
+// a file starts and finishes downloading and for each file every @interval
+func NewJSONProgress(w io.Writer, interval time.Duration) Progress {
+	p := &jsonProgress{enc: json.NewEncoder(w)}
+	p.tracker = tracker{interval: interval, report: func(f *trackedFile) { p.write(f.event("progress")) }}
+	return p
+}
+
+func (p *jsonProgress) write(e Event) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.enc.Encode(e)
+}
+
+func (p *jsonProgress) Start([]string) { p.start() }
+func (p *jsonProgress) Stop()          { p.finish() }
+
+func (p *jsonProgress) File(url string) FileProgress {
+	return &jsonFile{trackedFile: trackedFile{url: url, name: fileNameFromURL(url), total: -1}, progress: p}
+}
+
+type jsonFile struct {
+	trackedFile
+	progress *jsonProgress
+}
+
+func (f *jsonFile) SetTotal(size int64) {
+	atomic.StoreInt64(&f.total, size)
+	f.progress.write(f.event("start"))
+	f.progress.add(&f.trackedFile)
+}
+
+func (f *jsonFile) Done(result Result) {
+	f.progress.remove(&f.trackedFile)
+
+	e := f.event("done")
+	e.Downloaded = result.Size
+	if e.Size < 0 && result.Err == nil {
+		// cached files are never started
+		e.Size = result.Size
+	}
+	e.DurationMS = int64(result.Duration / time.Millisecond)
+	if result.Checksum.Algorithm != "" {
+		e.Checksum = result.Checksum.String()
+	}
+	e.Verified, e.Cached = result.Verified, result.Cached
+	if result.Err != nil {
+		e.Error = result.Err.Error()
+	}
+	f.progress.write(e)
+}
@@ -0,0 +1,101 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func Test_PlainProgress(t *testing.T) {
+	out := &syncBuffer{}
+	p := NewPlainProgress(out, 10*time.Millisecond)
+	p.Start([]string{"http://example.com/a.deb"})
+
+	f := p.File("http://example.com/a.deb")
+	f.SetTotal(200)
+	f.Set(50)
+	f.Add(50)
+	time.Sleep(50 * time.Millisecond)
+	f.Done(Result{Size: 200})
+	p.Stop()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if lines[0] != "Downloading a.deb (200 bytes)" {
+		t.Errorf("NewPlainProgress() first line\nExpected: %q,\nactual %q", "Downloading a.deb (200 bytes)", lines[0])
+	}
+	if len(lines) < 2 || lines[1] != "a.deb: 50% (100 of 200 bytes)" {
+		t.Errorf("NewPlainProgress() was supposed to report progress periodically but wrote %q", lines)
+	}
+
+	// nothing is reported once stopped
+	written := out.String()
+	time.Sleep(30 * time.Millisecond)
+	if out.String() != written {
+		t.Errorf("NewPlainProgress() kept writing after Stop()")
+	}
+}
+
+func Test_JSONProgress_ToFiles(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse("hello world")
+
+	out := &syncBuffer{}
+	urls := []string{"http://example.com/a.deb", "http://example.com/b.deb"}
+	checksums := map[string]Checksum{"a.deb": {Algorithm: SHA256, Sum: helloWorldSHA256}}
+	ToFiles(context.Background(), client, urls, Options{Dir: t.TempDir(), Checksums: checksums, Workers: 1, Progress: NewJSONProgress(out, 0)})
+
+	var events []Event
+	dec := json.NewDecoder(strings.NewReader(out.String()))
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("NewJSONProgress() wrote an invalid event: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	expected := []Event{
+		{Event: "start", URL: urls[0], File: "a.deb", Size: 11},
+		{Event: "done", URL: urls[0], File: "a.deb", Size: 11, Downloaded: 11, Checksum: "sha256:" + helloWorldSHA256, Verified: true},
+		// b.deb fails as it has no checksum
+		{Event: "start", URL: urls[1], File: "b.deb", Size: 11},
+		{Event: "done", URL: urls[1], File: "b.deb", Size: 11},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("NewJSONProgress()\nExpected: %+v,\nactual %+v", expected, events)
+	}
+	for i, e := range events {
+		e.DurationMS = 0
+		if e.Event == "done" && (e.Error != "") != (i == 3) {
+			t.Errorf("NewJSONProgress() event %d has an unexpected error %q", i, e.Error)
+		}
+		e.Error = ""
+		if e != expected[i] {
+			t.Errorf("NewJSONProgress() event %d\nExpected: %+v,\nactual %+v", i, expected[i], e)
+		}
+	}
+}
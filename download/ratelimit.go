@@ -0,0 +1,110 @@
+package download
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+// Limiter limits the rate of bytes passing through it with a token
+// bucket, it can be shared by any number of readers and goroutines.
+// A nil *Limiter doesn't limit anything.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter letting through @bytesPerSecond bytes
+// per second, or nil (i.e. no limit) when @bytesPerSecond isn't positive
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	// a tenth of a second worth of bytes keeps the rate steady
+	burst := float64(bytesPerSecond) / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: float64(bytesPerSecond), burst: burst, tokens: burst}
+}
+
+// WaitN waits until @n bytes can pass or @ctx is done
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	if d := l.reserve(n, time.Now()); d > 0 {
+		return http.Sleep(ctx, d)
+	}
+	return nil
+}
+
+// reserve takes @n tokens at @now returning how long
+// to wait until the bucket isn't in debt anymore
+func (l *Limiter) reserve(n int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// chunk returns how many bytes to read at once to stay close to the limit
+func (l *Limiter) chunk() int {
+	return int(l.burst)
+}
+
+// LimitReader returns a reader of @r which reads no faster than any
+// of @limiters allow and fails with @ctx's error once it's done
+func LimitReader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	var active []*Limiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiters: active}
+}
+
+type limitedReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	for _, l := range r.limiters {
+		if c := l.chunk(); len(p) > c {
+			p = p[:c]
+		}
+	}
+
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		if waitErr := l.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
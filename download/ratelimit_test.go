@@ -0,0 +1,81 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Limiter_reserve(t *testing.T) {
+	l := NewLimiter(1000)
+	start := time.Now()
+
+	tests := []struct {
+		n        int
+		at       time.Duration
+		expected time.Duration
+	}{
+		// the 100 bytes burst passes right away
+		{100, 0, 0},
+		{100, 0, 100 * time.Millisecond},
+		// the debt of 100 bytes was paid off
+		{50, 100 * time.Millisecond, 50 * time.Millisecond},
+		// unused tokens are capped at the burst
+		{300, 10 * time.Second, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if actual := l.reserve(tt.n, start.Add(tt.at)); actual != tt.expected {
+			t.Errorf("reserve(%d, +%v)\nExpected: %v,\nactual %v", tt.n, tt.at, tt.expected, actual)
+		}
+	}
+}
+
+func Test_NewLimiter_Unlimited(t *testing.T) {
+	for _, rate := range []int64{0, -1} {
+		if l := NewLimiter(rate); l != nil {
+			t.Errorf("NewLimiter(%d)\nExpected: nil,\nactual %+v", rate, l)
+		}
+	}
+
+	var l *Limiter
+	if err := l.WaitN(context.Background(), 1<<30); err != nil {
+		t.Errorf("WaitN() on a nil *Limiter returned an unexpected error: %v", err)
+	}
+
+	r := strings.NewReader("hello world")
+	if LimitReader(context.Background(), r, nil, nil) != r {
+		t.Errorf("LimitReader() without limiters was supposed to return the reader itself")
+	}
+}
+
+func Test_LimitReader(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 5000)
+	global, file := NewLimiter(20000), NewLimiter(10000)
+
+	start := time.Now()
+	data, err := ioutil.ReadAll(LimitReader(context.Background(), bytes.NewReader(content), global, file))
+	elapsed := time.Since(start)
+
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("LimitReader() read %d bytes, error %v", len(data), err)
+	}
+	// the slower limiter wins: 4000 bytes past its burst at 10000 bytes per second
+	if elapsed < 350*time.Millisecond {
+		t.Errorf("LimitReader() read 5000 bytes in %v, expected at least 400ms", elapsed)
+	}
+}
+
+func Test_LimitReader_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := LimitReader(ctx, bytes.NewReader(make([]byte, 1000)), NewLimiter(10))
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := io.Copy(ioutil.Discard, r); !errors.Is(err, context.Canceled) {
+		t.Errorf("LimitReader() was supposed to fail with context.Canceled but returned %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package download
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LatestLink is the name of the symlink pointing
+// to the most recently downloaded release
+const LatestLink = "latest"
+
+// LinkLatest atomically points the LatestLink symlink in @dir to @target,
+// a path relative to @dir, replacing the symlink if it already exists
+func LinkLatest(dir, target string) error {
+	link := filepath.Join(dir, LatestLink)
+	tmp := link + partSuffix
+
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("error creating symlink %v, error : %v", tmp, err)
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error renaming %v, error : %v", tmp, err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_LinkLatest(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, target := range []string{"v6.8.1", "v6.8.2"} {
+		if err := LinkLatest(dir, target); err != nil {
+			t.Fatalf("LinkLatest(%q) returned an unexpected error: %v", target, err)
+		}
+
+		actual, err := os.Readlink(filepath.Join(dir, LatestLink))
+		if err != nil || actual != target {
+			t.Errorf("LinkLatest(%q)\nExpected: %q,\nactual %q, %v", target, target, actual, err)
+		}
+	}
+}
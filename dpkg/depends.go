@@ -0,0 +1,227 @@
+package dpkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// e.g. "libc6 (>= 2.38)", "debconf-2.0" or "python3:any"
+var regRelation = regexp.MustCompile(`^([a-z0-9][a-z0-9+.-]*)(?::[a-z0-9-]+)?(?:\s*\(\s*(<<|<=|=|>=|>>|<|>)\s*([0-9A-Za-z][^\s)]*)\s*\))?$`)
+
+// Relation is a single package relation e.g. "libc6 (>= 2.38)"
+type Relation struct {
+	Name string
+	// Op is one of <<, <=, =, >= and >>, empty when any version will do
+	Op      string
+	Version string
+}
+
+func (r Relation) String() string {
+	if r.Op == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s (%s %s)", r.Name, r.Op, r.Version)
+}
+
+// Allows returns whether @version satisfies the relation's version
+// constraint, an invalid @version never does
+func (r Relation) Allows(version string) bool {
+	if r.Op == "" {
+		return true
+	}
+
+	c, err := versionutils.CompareDebianVersions(version, r.Version)
+	if err != nil {
+		return false
+	}
+	switch r.Op {
+	case "<<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=":
+		return c >= 0
+	default:
+		return c > 0
+	}
+}
+
+// Dependency is an entry of a Depends field, any of its
+// alternatives separated by | satisfies it
+type Dependency []Relation
+
+func (d Dependency) String() string {
+	alternatives := make([]string, 0, len(d))
+	for _, r := range d {
+		alternatives = append(alternatives, r.String())
+	}
+	return strings.Join(alternatives, " | ")
+}
+
+// ParseDepends parses a Depends or Provides field e.g.
+// "kmod, linux-base (>= 4.5ubuntu1~16.04.1), debconf | debconf-2.0".
+// Architecture qualifiers are dropped and the obsolete < and > operators
+// are turned into the <= and >= ones they stand for.
+func ParseDepends(field string) ([]Dependency, error) {
+	var depends []Dependency
+	for _, entry := range strings.Split(field, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		var d Dependency
+		for _, alternative := range strings.Split(entry, "|") {
+			m := regRelation.FindStringSubmatch(strings.TrimSpace(alternative))
+			if m == nil {
+				return nil, fmt.Errorf("invalid relation %q", strings.TrimSpace(alternative))
+			}
+
+			r := Relation{Name: m[1], Op: m[2], Version: m[3]}
+			if r.Op != "" {
+				if _, err := versionutils.ParseDebianVersion(r.Version); err != nil {
+					return nil, fmt.Errorf("invalid relation %q: %v", strings.TrimSpace(alternative), err)
+				}
+			}
+			switch r.Op {
+			case "<":
+				r.Op = "<="
+			case ">":
+				r.Op = ">="
+			}
+			d = append(d, r)
+		}
+		depends = append(depends, d)
+	}
+	return depends, nil
+}
+
+// Unsatisfied is a dependency of a package which would be broken
+type Unsatisfied struct {
+	// Package is the name of the package with the dependency
+	Package    string
+	Dependency Dependency
+	// Available holds the packages named by the dependency's alternatives,
+	// installed or about to be, whose versions don't satisfy it
+	Available []Package
+}
+
+func (u Unsatisfied) String() string {
+	msg := fmt.Sprintf("%s depends on %v", u.Package, u.Dependency)
+	if len(u.Available) == 0 {
+		return msg + ", not installed"
+	}
+
+	available := make([]string, 0, len(u.Available))
+	for _, p := range u.Available {
+		available = append(available, p.Name+" "+p.Version)
+	}
+	return msg + ", available " + strings.Join(available, ", ")
+}
+
+// CheckDepends returns the dependencies of @packages which neither the
+// @installed packages nor @packages themselves satisfy, i.e. which would
+// make dpkg fail to configure @packages. Packages of @packages replace
+// the installed ones of the same name since they'll upgrade them.
+func CheckDepends(packages, installed []Package) ([]Unsatisfied, error) {
+	available := map[string]Package{}
+	for _, p := range installed {
+		if p.Installed() {
+			available[p.Name] = p
+		}
+	}
+	for _, p := range packages {
+		available[p.Name] = p
+	}
+
+	// virtual packages e.g. debconf-2.0 provided by debconf
+	provided := map[string][]Relation{}
+	for name, p := range available {
+		provides, err := ParseDepends(p.Provides)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Provides of %s: %v", name, err)
+		}
+		for _, d := range provides {
+			for _, r := range d {
+				provided[r.Name] = append(provided[r.Name], r)
+			}
+		}
+	}
+
+	var unsatisfied []Unsatisfied
+	for _, p := range packages {
+		depends, err := ParseDepends(p.Depends)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Depends of %s: %v", p.Name, err)
+		}
+
+		for _, d := range depends {
+			if satisfied(d, available, provided) {
+				continue
+			}
+
+			u := Unsatisfied{Package: p.Name, Dependency: d}
+			for _, r := range d {
+				if candidate, ok := available[r.Name]; ok {
+					u.Available = append(u.Available, candidate)
+				}
+			}
+			unsatisfied = append(unsatisfied, u)
+		}
+	}
+	return unsatisfied, nil
+}
+
+// satisfied returns whether any alternative of @d is satisfied by the
+// @available packages or the virtual ones they've @provided
+func satisfied(d Dependency, available map[string]Package, provided map[string][]Relation) bool {
+	for _, r := range d {
+		if p, ok := available[r.Name]; ok && r.Allows(p.Version) {
+			return true
+		}
+		for _, provide := range provided[r.Name] {
+			// only versioned provides satisfy versioned dependencies
+			if r.Op == "" || (provide.Op == "=" && r.Allows(provide.Version)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Downgrade is a package about to be installed which is older
+// than the installed package of the same name
+type Downgrade struct {
+	Package   Package
+	Installed Package
+}
+
+func (d Downgrade) String() string {
+	return fmt.Sprintf("%s %s is older than the installed %s", d.Package.Name, d.Package.Version, d.Installed.Version)
+}
+
+// Downgrades returns the @packages which would replace newer @installed ones
+func Downgrades(packages, installed []Package) []Downgrade {
+	byName := map[string]Package{}
+	for _, p := range installed {
+		if p.Installed() {
+			byName[p.Name] = p
+		}
+	}
+
+	var downgrades []Downgrade
+	for _, p := range packages {
+		i, ok := byName[p.Name]
+		if !ok {
+			continue
+		}
+		if c, err := versionutils.CompareDebianVersions(p.Version, i.Version); err == nil && c < 0 {
+			downgrades = append(downgrades, Downgrade{Package: p, Installed: i})
+		}
+	}
+	return downgrades
+}
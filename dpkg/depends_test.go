@@ -0,0 +1,153 @@
+package dpkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseDepends(t *testing.T) {
+	depends, err := ParseDepends("kmod, linux-base (>= 4.5ubuntu1~16.04.1), debconf (>= 0.5) | debconf-2.0, python3:any, libfoo (> 1.0),")
+	if err != nil {
+		t.Fatalf("ParseDepends() returned an unexpected error: %v", err)
+	}
+
+	expected := []Dependency{
+		{{Name: "kmod"}},
+		{{Name: "linux-base", Op: ">=", Version: "4.5ubuntu1~16.04.1"}},
+		{{Name: "debconf", Op: ">=", Version: "0.5"}, {Name: "debconf-2.0"}},
+		{{Name: "python3"}},
+		{{Name: "libfoo", Op: ">=", Version: "1.0"}},
+	}
+	if !reflect.DeepEqual(depends, expected) {
+		t.Errorf("ParseDepends()\nExpected: %v,\nactual %v", expected, depends)
+	}
+
+	for _, field := range []string{"libc6 (>= )", "libc6 (~= 2.38)", "libc6 | ", "libc6 (>= v2.38)"} {
+		if _, err := ParseDepends(field); err == nil {
+			t.Errorf("ParseDepends(%q) was supposed to return an error", field)
+		}
+	}
+}
+
+func Test_Relation_Allows(t *testing.T) {
+	tests := []struct {
+		relation Relation
+		version  string
+		expected bool
+	}{
+		{Relation{Name: "kmod"}, "30+20221128-1ubuntu1", true},
+		{Relation{Name: "libc6", Op: ">=", Version: "2.38"}, "2.35-0ubuntu3.6", false},
+		{Relation{Name: "libc6", Op: ">=", Version: "2.38"}, "2.39-0ubuntu8", true},
+		{Relation{Name: "libc6", Op: "<<", Version: "2.38"}, "2.38~rc1", true},
+		{Relation{Name: "libc6", Op: "<=", Version: "2.38"}, "2.38", true},
+		{Relation{Name: "libc6", Op: "=", Version: "2.38"}, "2.38-1", false},
+		{Relation{Name: "libc6", Op: ">>", Version: "2.38"}, "2.38", false},
+		{Relation{Name: "libc6", Op: ">=", Version: "2.38"}, "1:2.0", true},
+		{Relation{Name: "libc6", Op: ">=", Version: "2.38"}, "invalid", false},
+	}
+
+	for _, tt := range tests {
+		if actual := tt.relation.Allows(tt.version); actual != tt.expected {
+			t.Errorf("%v.Allows(%q)\nExpected: %v,\nactual %v", tt.relation, tt.version, tt.expected, actual)
+		}
+	}
+}
+
+func Test_CheckDepends(t *testing.T) {
+	installed := []Package{
+		{Name: "libc6", Version: "2.35-0ubuntu3.6", Status: "install ok installed"},
+		{Name: "kmod", Version: "29-1ubuntu1", Status: "install ok installed"},
+		{Name: "debconf", Version: "1.5.79ubuntu1", Status: "install ok installed", Provides: "debconf-2.0"},
+		{Name: "libssl3", Version: "3.0.2-0ubuntu1.15", Status: "deinstall ok config-files"},
+		{Name: "linux-base", Version: "4.5ubuntu9", Status: "install ok installed"},
+	}
+	packages := []Package{
+		{Name: "linux-headers-6.8.2-060802", Version: "6.8.2-060802.202403271436"},
+		{
+			Name:    "linux-headers-6.8.2-060802-generic",
+			Version: "6.8.2-060802.202403271436",
+			Depends: "linux-headers-6.8.2-060802, libc6 (>= 2.38), libssl3 (>= 3.0.0)",
+		},
+		{
+			Name:    "linux-image-unsigned-6.8.2-060802-generic",
+			Version: "6.8.2-060802.202403271436",
+			Depends: "kmod, linux-base (>= 4.5ubuntu1~16.04.1), linux-modules-6.8.2-060802-generic, debconf (>= 2.0) | debconf-2.0",
+		},
+		{Name: "linux-modules-6.8.2-060802-generic", Version: "6.8.2-060802.202403271436"},
+	}
+
+	unsatisfied, err := CheckDepends(packages, installed)
+	if err != nil {
+		t.Fatalf("CheckDepends() returned an unexpected error: %v", err)
+	}
+
+	expected := []Unsatisfied{
+		{
+			Package:    "linux-headers-6.8.2-060802-generic",
+			Dependency: Dependency{{Name: "libc6", Op: ">=", Version: "2.38"}},
+			Available:  []Package{installed[0]},
+		},
+		{
+			Package:    "linux-headers-6.8.2-060802-generic",
+			Dependency: Dependency{{Name: "libssl3", Op: ">=", Version: "3.0.0"}},
+		},
+	}
+	if !reflect.DeepEqual(unsatisfied, expected) {
+		t.Errorf("CheckDepends()\nExpected: %v,\nactual %v", expected, unsatisfied)
+	}
+
+	messages := []string{
+		"linux-headers-6.8.2-060802-generic depends on libc6 (>= 2.38), available libc6 2.35-0ubuntu3.6",
+		"linux-headers-6.8.2-060802-generic depends on libssl3 (>= 3.0.0), not installed",
+	}
+	for i, u := range unsatisfied {
+		if i < len(messages) && u.String() != messages[i] {
+			t.Errorf("Unsatisfied.String()\nExpected: %q,\nactual %q", messages[i], u.String())
+		}
+	}
+}
+
+func Test_CheckDepends_Upgrade(t *testing.T) {
+	installed := []Package{{Name: "linux-base", Version: "4.0", Status: "install ok installed"}}
+	packages := []Package{
+		{Name: "linux-base", Version: "4.5ubuntu9"},
+		{Name: "linux-image", Version: "1", Depends: "linux-base (>= 4.5)"},
+		{Name: "linux-tools", Version: "1", Depends: "linux-base (<< 4.5)"},
+	}
+
+	unsatisfied, err := CheckDepends(packages, installed)
+	if err != nil {
+		t.Fatalf("CheckDepends() returned an unexpected error: %v", err)
+	}
+	if len(unsatisfied) != 1 || unsatisfied[0].Package != "linux-tools" {
+		t.Errorf("CheckDepends() was supposed to check against the upgraded linux-base only, actual %v", unsatisfied)
+	}
+
+	if _, err := CheckDepends([]Package{{Name: "a", Depends: "b ("}}, nil); err == nil {
+		t.Errorf("CheckDepends() was supposed to return an error for an invalid Depends")
+	}
+}
+
+func Test_Downgrades(t *testing.T) {
+	installed := []Package{
+		{Name: "linux-headers-6.8.2-060802", Version: "6.8.2-060802.202404011200", Status: "install ok installed"},
+		{Name: "linux-modules-6.8.2-060802-generic", Version: "6.8.2-060802.202403271436", Status: "install ok installed"},
+		{Name: "linux-image-unsigned-6.8.2-060802-generic", Version: "6.8.2-060802.202404011200", Status: "deinstall ok config-files"},
+	}
+	packages := []Package{
+		{Name: "linux-headers-6.8.2-060802", Version: "6.8.2-060802.202403271436"},
+		{Name: "linux-modules-6.8.2-060802-generic", Version: "6.8.2-060802.202403271436"},
+		{Name: "linux-image-unsigned-6.8.2-060802-generic", Version: "6.8.2-060802.202403271436"},
+	}
+
+	downgrades := Downgrades(packages, installed)
+	expected := []Downgrade{{Package: packages[0], Installed: installed[0]}}
+	if !reflect.DeepEqual(downgrades, expected) {
+		t.Errorf("Downgrades()\nExpected: %v,\nactual %v", expected, downgrades)
+	}
+
+	const message = "linux-headers-6.8.2-060802 6.8.2-060802.202403271436 is older than the installed 6.8.2-060802.202404011200"
+	if len(downgrades) == 1 && downgrades[0].String() != message {
+		t.Errorf("Downgrade.String()\nExpected: %q,\nactual %q", message, downgrades[0].String())
+	}
+}
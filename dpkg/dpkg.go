@@ -0,0 +1,127 @@
+// Package dpkg installs .debs with dpkg through a Runner,
+// so that the commands can be printed or faked instead
+package dpkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures Install
+type Options struct {
+	// Sudo runs dpkg with sudo, for when not running as root
+	Sudo bool
+}
+
+// command returns @args prefixed with sudo when needed
+func (o Options) command(args ...string) []string {
+	if o.Sudo {
+		return append([]string{"sudo"}, args...)
+	}
+	return args
+}
+
+// Command returns the command installing @debs
+func Command(debs []string, opts Options) []string {
+	return opts.command(append([]string{"dpkg", "-i"}, debs...)...)
+}
+
+// Result describes the outcome of installing a single .deb
+type Result struct {
+	// Deb is the path of the .deb
+	Deb string
+	// Err is set when dpkg couldn't install the .deb
+	Err error
+}
+
+// Error is returned when dpkg fails to install some of the .debs
+type Error struct {
+	// Command is the command the .debs were installed with
+	Command []string
+	// Failed holds the results of the .debs dpkg couldn't install
+	Failed []Result
+	Err    error
+}
+
+func (e *Error) Error() string {
+	failed := make([]string, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		failed = append(failed, filepath.Base(r.Deb))
+	}
+	return fmt.Sprintf("installing %s failed: %v", strings.Join(failed, ", "), e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Install installs @debs, in the given order, with a single dpkg command
+// using @runner so that packages depending on each other e.g. the modules
+// and the image of a release are configured together. It returns a result
+// for each of @debs and an *Error when dpkg fails. The .debs which failed
+// are taken from the "Errors were encountered while processing" list dpkg
+// writes to stderr, all of them fail when dpkg doesn't write one.
+func Install(ctx context.Context, runner Runner, debs []string, opts Options) ([]Result, error) {
+	command := Command(debs, opts)
+	results := make([]Result, len(debs))
+	for i, deb := range debs {
+		results[i] = Result{Deb: deb}
+	}
+
+	err := runner.Run(ctx, command[0], command[1:]...)
+	if err == nil {
+		return results, nil
+	}
+
+	var stderr string
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		stderr = exitErr.Stderr
+	}
+	failed := parseFailed(stderr)
+
+	installErr := &Error{Command: command, Err: err}
+	for i, deb := range debs {
+		name := strings.SplitN(filepath.Base(deb), "_", 2)[0]
+		if len(failed) == 0 || failed[deb] || failed[name] {
+			results[i].Err = err
+			installErr.Failed = append(installErr.Failed, results[i])
+		}
+	}
+	return results, installErr
+}
+
+// parseFailed returns the packages and .debs listed by dpkg's @stderr
+// after "Errors were encountered while processing:", one per line
+// indented by a space, without their architecture qualifiers
+func parseFailed(stderr string) map[string]bool {
+	failed := map[string]bool{}
+	lines := strings.Split(stderr, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "Errors were encountered while processing:" {
+			continue
+		}
+		for _, entry := range lines[i+1:] {
+			if !strings.HasPrefix(entry, " ") || strings.TrimSpace(entry) == "" {
+				break
+			}
+			entry = strings.TrimSpace(entry)
+			if !strings.HasSuffix(entry, ".deb") {
+				entry = strings.SplitN(entry, ":", 2)[0]
+			}
+			failed[entry] = true
+		}
+	}
+	return failed
+}
+
+// Purge removes @packages, including their configuration, with
+// a single dpkg command using @runner so that packages depending on
+// each other can be removed together
+func Purge(ctx context.Context, runner Runner, packages []string, opts Options) error {
+	command := opts.command(append([]string{"dpkg", "--purge"}, packages...)...)
+	return runner.Run(ctx, command[0], command[1:]...)
+}
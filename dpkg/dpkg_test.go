@@ -0,0 +1,177 @@
+package dpkg
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands failing the ones containing fail
+type fakeRunner struct {
+	commands []string
+	fail     string
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args ...string) error {
+	command := CommandString(append([]string{name}, args...))
+	r.commands = append(r.commands, command)
+	if r.fail != "" && strings.Contains(command, r.fail) {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+var installTestDebs = []string{
+	"/tmp/linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+	"/tmp/linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	"/tmp/linux-modules-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	"/tmp/linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+}
+
+// fakeDpkg fails to configure the packages of the .debs it's given
+// whose dependencies are neither installed nor given along with them
+type fakeDpkg struct {
+	commands  []string
+	depends   map[string][]string
+	installed map[string]bool
+}
+
+func (r *fakeDpkg) Run(ctx context.Context, name string, args ...string) error {
+	command := append([]string{name}, args...)
+	r.commands = append(r.commands, CommandString(command))
+
+	given := map[string]bool{}
+	for _, arg := range args {
+		given[strings.SplitN(filepath.Base(arg), "_", 2)[0]] = true
+	}
+
+	var failed []string
+	for _, arg := range args {
+		pkg := strings.SplitN(filepath.Base(arg), "_", 2)[0]
+		for _, d := range r.depends[pkg] {
+			if !given[d] && !r.installed[d] {
+				failed = append(failed, pkg)
+				break
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	stderr := "dpkg: dependency problems prevent configuration of " + failed[0] + ":\n"
+	stderr += "Errors were encountered while processing:\n " + strings.Join(failed, "\n ") + "\n"
+	return &ExitError{Command: command, Stderr: stderr, Err: errors.New("exit status 1")}
+}
+
+// the modules and the image of a release depend on each other
+var installTestDepends = map[string][]string{
+	"linux-headers-6.8.2-060802-generic":        {"linux-headers-6.8.2-060802", "libc6"},
+	"linux-modules-6.8.2-060802-generic":        {"linux-image-unsigned-6.8.2-060802-generic"},
+	"linux-image-unsigned-6.8.2-060802-generic": {"linux-modules-6.8.2-060802-generic", "kmod"},
+}
+
+func Test_Install(t *testing.T) {
+	runner := &fakeDpkg{depends: installTestDepends, installed: map[string]bool{"libc6": true, "kmod": true}}
+
+	// installing the modules and the image one at a time can't work
+	for _, deb := range installTestDebs[2:] {
+		if err := runner.Run(context.Background(), "dpkg", "-i", deb); err == nil {
+			t.Fatalf("fakeDpkg was supposed to fail installing %s on its own", deb)
+		}
+	}
+	runner.commands = nil
+
+	results, err := Install(context.Background(), runner, installTestDebs, Options{Sudo: true})
+	if err != nil {
+		t.Fatalf("Install() returned an unexpected error: %v", err)
+	}
+
+	expected := []string{"sudo dpkg -i " + strings.Join(installTestDebs, " ")}
+	if !reflect.DeepEqual(runner.commands, expected) {
+		t.Errorf("Install()\nExpected: %v,\nactual %v", expected, runner.commands)
+	}
+	for i, r := range results {
+		if r.Deb != installTestDebs[i] || r.Err != nil {
+			t.Errorf("Install() returned an unexpected result %+v", r)
+		}
+	}
+}
+
+func Test_Install_Failure(t *testing.T) {
+	// kmod isn't installed so the image fails to configure
+	runner := &fakeDpkg{depends: installTestDepends, installed: map[string]bool{"libc6": true}}
+	results, err := Install(context.Background(), runner, installTestDebs, Options{})
+
+	installErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Install() was supposed to return an *Error but returned %v", err)
+	}
+	if len(installErr.Failed) != 1 || installErr.Failed[0].Deb != installTestDebs[3] {
+		t.Errorf("Install() returned an unexpected error %+v", installErr)
+	}
+
+	expectedMsg := "installing linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb failed: " +
+		"dpkg -i " + strings.Join(installTestDebs, " ") + " failed: exit status 1"
+	if err.Error() != expectedMsg {
+		t.Errorf("Error.Error()\nExpected: %q,\nactual %q", expectedMsg, err.Error())
+	}
+
+	if len(runner.commands) != 1 {
+		t.Errorf("Install() was supposed to run dpkg once but ran %v", runner.commands)
+	}
+	if results[0].Err != nil || results[1].Err != nil || results[2].Err != nil || results[3].Err == nil {
+		t.Errorf("Install() returned unexpected results %+v", results)
+	}
+
+	// without dpkg's list of failed packages every .deb failed
+	results, err = Install(context.Background(), &fakeRunner{fail: "dpkg"}, installTestDebs, Options{})
+	if installErr, ok := err.(*Error); !ok || len(installErr.Failed) != len(installTestDebs) {
+		t.Errorf("Install() was supposed to fail every .deb but returned %v", err)
+	}
+	for _, r := range results {
+		if r.Err == nil {
+			t.Errorf("Install() returned an unexpected result %+v", r)
+		}
+	}
+}
+
+func Test_parseFailed(t *testing.T) {
+	stderr := `dpkg: error processing archive /tmp/a_1.0_amd64.deb (--install):
+ cannot access archive: No such file or directory
+dpkg: dependency problems prevent configuration of b:amd64:
+ b:amd64 depends on c; however:
+  Package c is not installed.
+
+Errors were encountered while processing:
+ /tmp/a_1.0_amd64.deb
+ b:amd64
+`
+	expected := map[string]bool{"/tmp/a_1.0_amd64.deb": true, "b": true}
+	if actual := parseFailed(stderr); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("parseFailed()\nExpected: %v,\nactual %v", expected, actual)
+	}
+	if actual := parseFailed("dpkg: error: dpkg frontend lock was locked by another process\n"); len(actual) != 0 {
+		t.Errorf("parseFailed() was supposed to return no packages, actual %v", actual)
+	}
+}
+
+func Test_Purge(t *testing.T) {
+	runner := &fakeRunner{}
+	if err := Purge(context.Background(), runner, []string{"linux-headers-6.8.1-060801", "linux-image-unsigned-6.8.1-060801-generic"}, Options{Sudo: true}); err != nil {
+		t.Fatalf("Purge() returned an unexpected error: %v", err)
+	}
+
+	expected := []string{"sudo dpkg --purge linux-headers-6.8.1-060801 linux-image-unsigned-6.8.1-060801-generic"}
+	if !reflect.DeepEqual(runner.commands, expected) {
+		t.Errorf("Purge()\nExpected: %v,\nactual %v", expected, runner.commands)
+	}
+
+	runner = &fakeRunner{fail: "dpkg"}
+	if err := Purge(context.Background(), runner, []string{"a"}, Options{}); err == nil {
+		t.Errorf("Purge() was supposed to return the runner's error")
+	}
+}
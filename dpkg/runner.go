@@ -0,0 +1,81 @@
+package dpkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Runner runs commands
+type Runner interface {
+	// Run runs the command @name with @args until it exits or @ctx is done
+	Run(ctx context.Context, name string, args ...string) error
+}
+
+// ExecRunner runs commands with os/exec, forwarding their output
+type ExecRunner struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExitError is returned by ExecRunner when a command fails
+type ExitError struct {
+	Command []string
+	// Stderr is what the command wrote to its standard error
+	Stderr string
+	Err    error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s failed: %v", CommandString(e.Command), e.Err)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Run implements Runner, the command is killed when @ctx is done
+// so dpkg shouldn't be run with a context canceled by signals
+func (r ExecRunner) Run(ctx context.Context, name string, args ...string) error {
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = r.Stdout, stderr
+	if r.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(r.Stderr, stderr)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return &ExitError{Command: append([]string{name}, args...), Stderr: stderr.String(), Err: err}
+	}
+	return nil
+}
+
+// DryRunner writes commands to W instead of running them
+type DryRunner struct {
+	W io.Writer
+}
+
+// Run implements Runner
+func (r DryRunner) Run(ctx context.Context, name string, args ...string) error {
+	_, err := fmt.Fprintln(r.W, CommandString(append([]string{name}, args...)))
+	return err
+}
+
+var regSafeArg = regexp.MustCompile(`^[A-Za-z0-9_./:=+,@%-]+$`)
+
+// CommandString returns @command quoted so that it can be pasted into a shell
+func CommandString(command []string) string {
+	quoted := make([]string, 0, len(command))
+	for _, arg := range command {
+		if regSafeArg.MatchString(arg) {
+			quoted = append(quoted, arg)
+		} else {
+			quoted = append(quoted, "'"+strings.Replace(arg, "'", `'\''`, -1)+"'")
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,54 @@
+package dpkg
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func Test_CommandString(t *testing.T) {
+	tests := []struct {
+		command  []string
+		expected string
+	}{
+		{[]string{"dpkg", "-i", "/tmp/a_1.0+1_amd64.deb"}, "dpkg -i /tmp/a_1.0+1_amd64.deb"},
+		{[]string{"dpkg", "-i", "/tmp/my debs/a.deb"}, "dpkg -i '/tmp/my debs/a.deb'"},
+		{[]string{"dpkg", "-i", "it's.deb"}, `dpkg -i 'it'\''s.deb'`},
+		{[]string{"echo", ""}, "echo ''"},
+	}
+	for _, tt := range tests {
+		if actual := CommandString(tt.command); actual != tt.expected {
+			t.Errorf("CommandString(%q)\nExpected: %q,\nactual %q", tt.command, tt.expected, actual)
+		}
+	}
+}
+
+func Test_DryRunner(t *testing.T) {
+	buff := &bytes.Buffer{}
+	if err := (DryRunner{W: buff}).Run(context.Background(), "dpkg", "-i", "a b.deb"); err != nil {
+		t.Fatal(err)
+	}
+	if buff.String() != "dpkg -i 'a b.deb'\n" {
+		t.Errorf("DryRunner.Run()\nExpected: %q,\nactual %q", "dpkg -i 'a b.deb'\n", buff.String())
+	}
+}
+
+func Test_ExecRunner(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	r := ExecRunner{Stdout: stdout}
+
+	if err := r.Run(context.Background(), "echo", "hello"); err != nil || stdout.String() != "hello\n" {
+		t.Errorf("ExecRunner.Run(echo hello)\nExpected: %q, nil,\nactual %q, %v", "hello\n", stdout.String(), err)
+	}
+	if err := r.Run(context.Background(), "false"); err == nil {
+		t.Errorf("ExecRunner.Run(false) was supposed to return an error")
+	}
+
+	stderr := &bytes.Buffer{}
+	r.Stderr = stderr
+	err := r.Run(context.Background(), "sh", "-c", "echo oops >&2; exit 1")
+	exitErr, ok := err.(*ExitError)
+	if !ok || exitErr.Stderr != "oops\n" || stderr.String() != "oops\n" {
+		t.Errorf("ExecRunner.Run(sh) was supposed to return an *ExitError with its stderr, actual %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package dpkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StatusPath is the dpkg status database relative to the system root
+const StatusPath = "var/lib/dpkg/status"
+
+// Package is an entry of the dpkg status database
+type Package struct {
+	Name         string
+	Version      string
+	Architecture string
+	// Status is the "want flag state" triplet e.g. "install ok installed"
+	Status string
+	// Depends and Provides are the package's relation fields as
+	// they're written e.g. "libc6 (>= 2.38), kmod" or "debconf-2.0"
+	Depends  string
+	Provides string
+}
+
+// Installed returns whether the package's files are on the system, i.e.
+// it's neither removed with only its configuration left nor purged
+func (p Package) Installed() bool {
+	fields := strings.Fields(p.Status)
+	if len(fields) != 3 {
+		return false
+	}
+	return fields[2] != "not-installed" && fields[2] != "config-files"
+}
+
+// ReadStatus reads the dpkg status database of the system at @root
+func ReadStatus(root string) ([]Package, error) {
+	path := filepath.Join(root, StatusPath)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	packages, err := ParseStatus(f)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %v", path, err)
+	}
+	return packages, nil
+}
+
+// ParseStatus parses a dpkg status database from @r, its stanzas
+// are separated by empty lines and hold "Field: value" lines
+// continued by lines starting with a space
+func ParseStatus(r io.Reader) ([]Package, error) {
+	var (
+		packages []Package
+		current  Package
+	)
+	flush := func() {
+		if current.Name != "" {
+			packages = append(packages, current)
+		}
+		current = Package{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	// some descriptions have long lines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			// continuation of a multiline field
+			continue
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch line[:colon] {
+		case "Package":
+			current.Name = value
+		case "Version":
+			current.Version = value
+		case "Architecture":
+			current.Architecture = value
+		case "Status":
+			current.Status = value
+		case "Depends":
+			current.Depends = value
+		case "Provides":
+			current.Provides = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return packages, nil
+}
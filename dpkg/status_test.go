@@ -0,0 +1,97 @@
+package dpkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const statusTestData = `Package: bash
+Essential: yes
+Status: install ok installed
+Priority: required
+Architecture: amd64
+Version: 5.2.21-2ubuntu4
+Depends: base-files (>= 2.1.12), debianutils (>= 5.6-0.1)
+Description: GNU Bourne Again SHell
+ Bash is an sh-compatible command language interpreter.
+ .
+ Bash is ultimately intended to be a conformant implementation.
+
+Package: linux-image-unsigned-6.8.2-060802-generic
+Status: install ok installed
+Architecture: amd64
+Version: 6.8.2-060802.202403271436
+
+Package: linux-image-unsigned-6.5.1-060501-generic
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 6.5.1-060501.202309020842
+
+Package: debconf
+Status: install ok installed
+Architecture: all
+Version: 1.5.86ubuntu1
+Provides: debconf-2.0
+`
+
+func Test_ParseStatus(t *testing.T) {
+	packages, err := ParseStatus(strings.NewReader(statusTestData))
+	if err != nil {
+		t.Fatalf("ParseStatus() returned an unexpected error: %v", err)
+	}
+
+	expected := []Package{
+		{Name: "bash", Version: "5.2.21-2ubuntu4", Architecture: "amd64", Status: "install ok installed", Depends: "base-files (>= 2.1.12), debianutils (>= 5.6-0.1)"},
+		{Name: "linux-image-unsigned-6.8.2-060802-generic", Version: "6.8.2-060802.202403271436", Architecture: "amd64", Status: "install ok installed"},
+		{Name: "linux-image-unsigned-6.5.1-060501-generic", Version: "6.5.1-060501.202309020842", Architecture: "amd64", Status: "deinstall ok config-files"},
+		{Name: "debconf", Version: "1.5.86ubuntu1", Architecture: "all", Status: "install ok installed", Provides: "debconf-2.0"},
+	}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("ParseStatus()\nExpected: %+v,\nactual %+v", expected, packages)
+	}
+
+	if _, err := ParseStatus(strings.NewReader("Package bash\n")); err == nil {
+		t.Errorf("ParseStatus() was supposed to return an error for a line without a colon")
+	}
+}
+
+func Test_Package_Installed(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected bool
+	}{
+		{"install ok installed", true},
+		{"hold ok installed", true},
+		{"install ok half-configured", true},
+		{"deinstall ok config-files", false},
+		{"purge ok not-installed", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if actual := (Package{Status: tt.status}).Installed(); actual != tt.expected {
+			t.Errorf("Package{Status: %q}.Installed()\nExpected: %v,\nactual %v", tt.status, tt.expected, actual)
+		}
+	}
+}
+
+func Test_ReadStatus(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, StatusPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(statusTestData), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if packages, err := ReadStatus(root); err != nil || len(packages) != 4 {
+		t.Errorf("ReadStatus() returned %d packages, error %v", len(packages), err)
+	}
+	if _, err := ReadStatus(t.TempDir()); err == nil {
+		t.Errorf("ReadStatus() was supposed to return an error for a missing database")
+	}
+}
@@ -3,11 +3,15 @@ module github.com/pmalek/kernel_deb_downloader
 go 1.13
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.6
 	github.com/fatih/color v1.14.1 // indirect
+	github.com/klauspost/compress v1.16.7
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/pmalek/pb v1.0.13
 	github.com/pmalek/stringutils v0.0.0-20160613085703-c5d70074c6b9
 	github.com/rivo/uniseg v0.4.4 // indirect
-	golang.org/x/net v0.8.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/net v0.25.0
 	gopkg.in/cheggaaa/pb.v1 v1.0.28 // indirect
 )
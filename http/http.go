@@ -2,22 +2,27 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // Getter interface provides one method: Get, to
 // allow using it in places where we would like test
-// an HTTP client
+// an HTTP client. Requests are canceled with the context.
 type Getter interface {
-	Get(string) (*http.Response, error)
+	Get(context.Context, string) (*http.Response, error)
 }
 
 // Header interface provides one method: Head, to
 // allow using it in places where we would like test
-// an HTTP client
+// an HTTP client. Requests are canceled with the context.
 type Header interface {
-	Head(string) (*http.Response, error)
+	Head(context.Context, string) (*http.Response, error)
 }
 
 // GetterHeader interface combines Header and Getter
@@ -27,17 +32,182 @@ type GetterHeader interface {
 	Header
 }
 
+// RangeGetter interface provides one method: GetRange, to
+// allow resuming interrupted downloads. GetRange requests the
+// contents of url starting at byte offset, ifRange (an ETag or
+// a Last-Modified date) makes the server send the whole contents
+// when they changed. Servers which don't support ranges respond
+// with 200 and the whole contents instead of 206.
+type RangeGetter interface {
+	GetRange(ctx context.Context, url string, offset int64, ifRange string) (*http.Response, error)
+}
+
+// Client wraps *http.Client to fulfill the Getter, Header and
+// RangeGetter interfaces, timing out stalled requests and
+// retrying failed ones
+type Client struct {
+	*http.Client
+	// Timeout limits waiting for response headers and then for
+	// each read of the response body, so that a stalled request
+	// fails while a slow but steady download doesn't.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Retry is the policy for retrying requests which fail with
+	// a network error, 429 or a 5xx status code
+	Retry RetryPolicy
+}
+
+// NewClient returns a Client using @client, or http.DefaultClient
+// when @client is nil, and DefaultRetryPolicy
+func NewClient(client *http.Client) Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return Client{Client: client, Retry: DefaultRetryPolicy}
+}
+
+// Get issues a GET request for url
+func (c Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, url, nil)
+}
+
+// Head issues a HEAD request for url
+func (c Client) Head(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, http.MethodHead, url, nil)
+}
+
+// GetRange issues a GET request for the contents of url starting at offset
+func (c Client) GetRange(ctx context.Context, url string, offset int64, ifRange string) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	if ifRange != "" {
+		header.Set("If-Range", ifRange)
+	}
+
+	return c.do(ctx, http.MethodGet, url, header)
+}
+
+// do issues a request retrying it according to c.Retry. When all
+// attempts fail with 429 or 5xx the last response is returned.
+func (c Client) do(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doOnce(ctx, method, url, header)
+
+		retryErr := err
+		if err == nil && retryableStatus(resp.StatusCode) {
+			retryErr = CheckStatus(resp, url)
+		}
+		if retryErr == nil || !Retryable(retryErr) || attempt+1 >= c.Retry.Attempts() {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := Sleep(ctx, c.Retry.Backoff(attempt, retryErr)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c Client) doOnce(parent context.Context, method, url string, header http.Header) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(parent)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	body := &timeoutBody{timeout: c.Timeout, cancel: cancel}
+	if c.Timeout > 0 {
+		body.timer = time.AfterFunc(c.Timeout, body.expire)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		body.stop()
+		if body.expired() {
+			return nil, &timeoutError{url: url}
+		}
+		return nil, err
+	}
+
+	body.ReadCloser, body.url = resp.Body, url
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody cancels reading the response body
+// when a single read takes longer than timeout
+type timeoutBody struct {
+	io.ReadCloser
+	url      string
+	timeout  time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut int32
+}
+
+func (b *timeoutBody) expire() {
+	atomic.StoreInt32(&b.timedOut, 1)
+	b.cancel()
+}
+
+func (b *timeoutBody) expired() bool {
+	return atomic.LoadInt32(&b.timedOut) == 1
+}
+
+func (b *timeoutBody) stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.cancel()
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if b.timer != nil {
+		b.timer.Reset(b.timeout)
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.expired() {
+		err = &timeoutError{url: b.url}
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	b.stop()
+	return b.ReadCloser.Close()
+}
+
+// timeoutError is returned when a request times out, it's a net.Error
+type timeoutError struct {
+	url string
+}
+
+func (e *timeoutError) Error() string   { return "request for " + e.url + " timed out" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
 type nopCloser struct {
 	io.Reader
 }
 
 func (nopCloser) Close() error { return nil }
 
-// MockedClient fulfills the Getter interface
+// MockedClient fulfills the Getter, Header and RangeGetter interfaces
 type MockedClient struct {
-	response   string
-	err        error
-	statusCode int
+	response     string
+	urlResponses map[string]string
+	err          error
+	statusCode   int
+	etag         string
+	acceptRanges bool
 }
 
 // SetResponse sets the response that MockedClient
@@ -46,33 +216,110 @@ func (c *MockedClient) SetResponse(response string) {
 	c.response = response
 }
 
+// SetURLResponse sets the response that MockedClient will receive
+// when calling GET on @url, it takes precedence over SetResponse
+func (c *MockedClient) SetURLResponse(url, response string) {
+	if c.urlResponses == nil {
+		c.urlResponses = make(map[string]string)
+	}
+	c.urlResponses[url] = response
+}
+
+func (c MockedClient) responseFor(url string) string {
+	if response, ok := c.urlResponses[url]; ok {
+		return response
+	}
+	return c.response
+}
+
 // SetError sets the error that MockedClient will
 // receive after calling GET
 func (c *MockedClient) SetError(err error) {
 	c.err = err
 }
 
-// SetStatusCode sets the statusCode on MockedClient
+// SetStatusCode sets the statusCode on MockedClient, 200 is used by default
 func (c *MockedClient) SetStatusCode(statusCode int) {
 	c.statusCode = statusCode
 }
 
+func (c MockedClient) status() int {
+	if c.statusCode == 0 {
+		return http.StatusOK
+	}
+	return c.statusCode
+}
+
+// SetETag sets the ETag header MockedClient's responses carry
+func (c *MockedClient) SetETag(etag string) {
+	c.etag = etag
+}
+
+// SetAcceptRanges sets whether GetRange honours the requested range
+func (c *MockedClient) SetAcceptRanges(acceptRanges bool) {
+	c.acceptRanges = acceptRanges
+}
+
+func (c MockedClient) header() http.Header {
+	h := http.Header{}
+	if c.etag != "" {
+		h.Set("ETag", c.etag)
+	}
+	if c.acceptRanges {
+		h.Set("Accept-Ranges", "bytes")
+	}
+	return h
+}
+
 // Get returns a preset response body and a preset error
-func (c MockedClient) Get(url string) (*http.Response, error) {
+// or the error of @ctx when it's done
+func (c MockedClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	resp := &http.Response{
-		Body:       nopCloser{bytes.NewBufferString(c.response)},
-		StatusCode: c.statusCode,
+		Body:       nopCloser{bytes.NewBufferString(c.responseFor(url))},
+		StatusCode: c.status(),
+		Header:     c.header(),
 	}
 
 	return resp, c.err
 }
 
 // Head returns a preset response body and a preset error
-func (c MockedClient) Head(url string) (*http.Response, error) {
+// or the error of @ctx when it's done
+func (c MockedClient) Head(ctx context.Context, url string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	response := c.responseFor(url)
+	resp := &http.Response{
+		Body:          nopCloser{bytes.NewBufferString(response)},
+		StatusCode:    c.status(),
+		ContentLength: int64(len(response)),
+		Header:        c.header(),
+	}
+
+	return resp, c.err
+}
+
+// GetRange returns a preset response body starting at @offset with 206
+// status code when ranges are accepted and @ifRange matches the preset
+// ETag, otherwise it behaves like Get
+func (c MockedClient) GetRange(ctx context.Context, url string, offset int64, ifRange string) (*http.Response, error) {
+	response := c.responseFor(url)
+	if !c.acceptRanges || (ifRange != "" && ifRange != c.etag) || offset > int64(len(response)) || ctx.Err() != nil {
+		return c.Get(ctx, url)
+	}
+
+	h := c.header()
+	h.Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+strconv.Itoa(len(response)-1)+"/"+strconv.Itoa(len(response)))
 	resp := &http.Response{
-		Body:          nopCloser{bytes.NewBufferString(c.response)},
-		StatusCode:    c.statusCode,
-		ContentLength: int64(len(c.response)),
+		Body:       nopCloser{bytes.NewBufferString(response[offset:])},
+		StatusCode: http.StatusPartialContent,
+		Header:     h,
 	}
 
 	return resp, c.err
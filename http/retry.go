@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures retrying failed requests with
+// exponential backoff and jitter
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts made,
+	// values below 1 mean a single attempt
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry,
+	// each next one doubles it
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts,
+	// including the one requested with Retry-After
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by NewClient
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// NoRetries makes a single attempt
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// Attempts returns the number of attempts made by @p
+func (p RetryPolicy) Attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Backoff returns the delay before retrying after failed @attempt
+// (counted from 0): InitialBackoff doubled with each attempt, capped
+// at MaxBackoff and randomized to between half and all of it.
+// @err's Retry-After, if any, is used instead.
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	var d time.Duration
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		d = statusErr.RetryAfter
+	} else {
+		d = p.InitialBackoff
+		for i := 0; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+			d *= 2
+		}
+		if d > 0 {
+			d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+		}
+	}
+
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// Do calls @fn until it succeeds, returns an error which isn't
+// Retryable or the attempts run out, returning its last error.
+// Waiting between attempts stops when @ctx is done.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < p.Attempts(); attempt++ {
+		if attempt > 0 {
+			if sleepErr := Sleep(ctx, p.Backoff(attempt-1, err)); sleepErr != nil {
+				return sleepErr
+			}
+		}
+		if err = fn(); err == nil || !Retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Sleep waits for @d or until @ctx is done, returning its error then
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StatusError is returned for responses with unexpected status codes
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested with the Retry-After header
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Received %d HTTP status code from %s", e.StatusCode, e.URL)
+}
+
+// CheckStatus returns a *StatusError when @resp to
+// a request for @url doesn't have a 2xx status code
+func CheckStatus(resp *http.Response, url string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return &StatusError{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter parses Retry-After given either in seconds
+// or as an HTTP date, relative to @now
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// retryableStatus returns whether requests failing with
+// @statusCode are worth retrying i.e. 429 and 5xx
+func retryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Retryable returns whether @err is transient: a network
+// error, a truncated response, 429 or a 5xx status code.
+// Canceled requests aren't retryable.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
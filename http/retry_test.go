@@ -0,0 +1,201 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_RetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt  int
+		err      error
+		min, max time.Duration
+	}{
+		{0, nil, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, nil, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, nil, 500 * time.Millisecond, time.Second},
+		{0, &StatusError{StatusCode: 429, RetryAfter: 700 * time.Millisecond}, 700 * time.Millisecond, 700 * time.Millisecond},
+		{0, &StatusError{StatusCode: 503, RetryAfter: time.Hour}, time.Second, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.Backoff(tt.attempt, tt.err); d < tt.min || d > tt.max {
+				t.Errorf("Backoff(%d, %v) = %v, expected between %v and %v", tt.attempt, tt.err, d, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 27, 14, 36, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"Wed, 27 Mar 2024 14:36:30 GMT": 30 * time.Second,
+		"Wed, 27 Mar 2024 14:00:00 GMT": 0,
+		"soon":                          0,
+	}
+
+	for value, expected := range tests {
+		if actual := parseRetryAfter(value, now); actual != expected {
+			t.Errorf("parseRetryAfter(%q)\nExpected: %v,\nactual %v", value, expected, actual)
+		}
+	}
+}
+
+func Test_Retryable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&StatusError{StatusCode: 429}, true},
+		{&StatusError{StatusCode: 502}, true},
+		{&StatusError{StatusCode: 404}, false},
+		{fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 500}), true},
+		{&timeoutError{}, true},
+		{io.ErrUnexpectedEOF, true},
+		{errors.New("checksum mismatch"), false},
+	}
+
+	for _, tt := range tests {
+		if actual := Retryable(tt.err); actual != tt.expected {
+			t.Errorf("Retryable(%v)\nExpected: %t,\nactual %t", tt.err, tt.expected, actual)
+		}
+	}
+}
+
+func Test_RetryPolicy_Do(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return &StatusError{StatusCode: 503}
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("Do() was supposed to make 3 attempts and fail but made %d and returned %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return &StatusError{StatusCode: 404}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Do() wasn't supposed to retry a 404 but made %d attempts", calls)
+	}
+}
+
+func Test_Client_Retries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	client.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Get() returned an unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "ok" || requests != 3 {
+		t.Errorf("Get() was supposed to succeed on the 3rd attempt, made %d returning %q", requests, body)
+	}
+}
+
+func Test_Client_ReturnsLastResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	client.Retry = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Get() returned an unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if statusErr, ok := CheckStatus(resp, server.URL).(*StatusError); !ok || statusErr.StatusCode != 503 {
+		t.Errorf("CheckStatus() was supposed to return a 503 *StatusError but returned %v", statusErr)
+	}
+}
+
+func Test_Client_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(server.Client())
+	client.Timeout = 50 * time.Millisecond
+	client.Retry = NoRetries
+
+	resp, err := client.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Get() returned an unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	_, err = ioutil.ReadAll(resp.Body)
+	if _, ok := err.(*timeoutError); !ok {
+		t.Errorf("Reading a stalled body was supposed to return a *timeoutError but returned %v", err)
+	}
+}
+
+func Test_Client_Canceled(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client())
+	client.Retry = RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.Get(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() was supposed to stop retrying when the context is done but returned %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Get() was supposed to make 1 request but made %d", requests)
+	}
+	if Retryable(fmt.Errorf("wrapped: %w", context.Canceled)) {
+		t.Errorf("Retryable() was supposed to return false for a canceled request")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/deb"
+	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+)
+
+// runInspect implements the inspect subcommand which prints the control
+// fields of .debs and verifies their payload against their md5sums.
+// It returns the process exit code.
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	hostArch, hostArchErr := ubuntukernelpageutils.HostArch()
+	arch := fs.String("arch", hostArch, "Architecture the .debs are expected to be built for")
+	flavour := fs.String("flavour", "generic", "Kernel flavour the .debs are expected to be built in")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: inspect [flags] [file or directory...]\n\nInspects the given .debs and the .debs in the given directories (\".\" by default).\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *arch == "" && hostArchErr != nil {
+		fmt.Printf("%v, pass -arch\n", hostArchErr)
+		return 2
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var debs []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if !fi.IsDir() {
+			debs = append(debs, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.deb"))
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		debs = append(debs, matches...)
+	}
+	if len(debs) == 0 {
+		fmt.Printf("No .debs found in %s\n", strings.Join(paths, ", "))
+		return 1
+	}
+
+	failed := false
+	for i, path := range debs {
+		if i > 0 {
+			fmt.Println()
+		}
+		if !inspectDeb(path, *arch, *flavour) {
+			failed = true
+		}
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// inspectDeb prints the details of the .deb at @path, it
+// returns false when the .deb is corrupted or isn't built
+// for @arch and @flavour
+func inspectDeb(path, arch, flavour string) bool {
+	fmt.Println(path)
+
+	info, err := deb.InspectFile(path)
+	if err != nil {
+		fmt.Printf("  Error: %v\n", err)
+		return false
+	}
+
+	c := info.Control
+	fmt.Printf("  Package:        %s\n", c.Package)
+	fmt.Printf("  Version:        %s\n", c.Version)
+	fmt.Printf("  Architecture:   %s\n", c.Architecture)
+	fmt.Printf("  Installed-Size: %d KiB\n", c.InstalledSize)
+	fmt.Printf("  Depends:        %s\n", c.Depends)
+
+	ok := true
+	switch {
+	case !info.MD5Sums:
+		fmt.Printf("  Files:          %d, no md5sums to verify them against\n", info.Files)
+	case info.Valid():
+		fmt.Printf("  Files:          %d, all match md5sums\n", info.Files)
+	default:
+		fmt.Printf("  Files:          %d, some don't match md5sums\n", info.Files)
+		for _, f := range info.Mismatched {
+			fmt.Printf("    Mismatched: %s\n", f)
+		}
+		for _, f := range info.Missing {
+			fmt.Printf("    Missing: %s\n", f)
+		}
+		ok = false
+	}
+
+	if c.Architecture != "all" && c.Architecture != arch {
+		fmt.Printf("  Warning: built for %s, expected %s\n", c.Architecture, arch)
+		ok = false
+	}
+	p, isKernel := ubuntukernelpageutils.ParsePackageFileName(fmt.Sprintf("%s_%s_%s.deb", c.Package, c.Version, c.Architecture))
+	if isKernel && p.Flavour != "" && p.Flavour != flavour {
+		fmt.Printf("  Warning: %s flavour, expected %s\n", p.Flavour, flavour)
+		ok = false
+	}
+	return ok
+}
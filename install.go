@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/deb"
+	"github.com/pmalek/kernel_deb_downloader/dpkg"
+	"github.com/pmalek/kernel_deb_downloader/system"
+	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+)
+
+// runInstall implements the install subcommand which installs a release
+// downloaded into a directory with dpkg. It returns the process exit code.
+func runInstall(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	hostArch, hostArchErr := ubuntukernelpageutils.HostArch()
+	arch := fs.String("arch", hostArch, "Architecture of the .debs to install")
+	flavour := fs.String("flavour", "generic", "Kernel flavour of the .debs to install")
+	dryRun := fs.Bool("dry-run", false, "Print the dpkg commands instead of running them")
+	sudo := fs.Bool("sudo", os.Geteuid() != 0, "Run dpkg with sudo")
+	skipPreflight := fs.Bool("skip-preflight", false, "Install without checking the .debs' dependencies are satisfiable first")
+	root := fs.String("root", "/", "Root of the system whose dpkg database and os-release are read by the dependency check")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: install [flags] [directory]\n\nInstalls the release downloaded into directory (\".\" by default).\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *arch == "" && hostArchErr != nil {
+		fmt.Printf("%v, pass -arch\n", hostArchErr)
+		return 2
+	}
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+
+	packages, err := localPackages(dir, *arch, *flavour)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	debs := make([]string, 0, len(packages))
+	for _, p := range ubuntukernelpageutils.InstallOrder(packages) {
+		debs = append(debs, filepath.Join(dir, p.FileName()))
+	}
+
+	if !*skipPreflight {
+		if err := preflight(debs, *root); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+
+	var runner dpkg.Runner = dpkg.ExecRunner{Stdout: os.Stdout, Stderr: os.Stderr}
+	if *dryRun {
+		runner = dpkg.DryRunner{W: os.Stdout}
+	}
+
+	// dpkg isn't killed by SIGINT or SIGTERM, which would leave packages
+	// half configured, it gets Ctrl-C from the terminal and exits by itself
+	results, err := dpkg.Install(context.Background(), runner, debs, dpkg.Options{Sudo: *sudo})
+	if *dryRun {
+		return 0
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("Error installing %s: %v\n", r.Deb, r.Err)
+		} else {
+			fmt.Printf("Installed %s\n", r.Deb)
+		}
+	}
+	exitIfInterrupted(ctx)
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// localPackages returns the @flavour kernel .debs for @arch in @dir
+// making sure they're all of the same release and include an image
+func localPackages(dir, arch, flavour string) ([]ubuntukernelpageutils.Package, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []ubuntukernelpageutils.Package
+	versions := map[string]bool{}
+	hasImage := false
+	for _, f := range files {
+		p, ok := ubuntukernelpageutils.ParsePackageFileName(f.Name())
+		if !ok || f.IsDir() || !p.Matches(arch, flavour) {
+			continue
+		}
+		packages = append(packages, p)
+		versions[p.Version] = true
+		hasImage = hasImage || p.Kind == ubuntukernelpageutils.KindImage
+	}
+
+	switch {
+	case len(versions) > 1:
+		var found []string
+		for v := range versions {
+			found = append(found, v)
+		}
+		sort.Strings(found)
+		return nil, fmt.Errorf("Found .debs of several releases in %s: %s, expected a single one", dir, strings.Join(found, ", "))
+	case !hasImage:
+		return nil, fmt.Errorf("No %s %s kernel image .deb found in %s", arch, flavour, dir)
+	}
+	return packages, nil
+}
+
+// preflight checks the dependencies of @debs are satisfied by the packages
+// installed on the system at @root or by @debs themselves, so that dpkg
+// doesn't fail half way through installing them. It warns about @debs
+// older than the installed packages they'd replace.
+func preflight(debs []string, root string) error {
+	packages := make([]dpkg.Package, 0, len(debs))
+	for _, path := range debs {
+		c, err := deb.ReadControlFile(path)
+		if err != nil {
+			return err
+		}
+		packages = append(packages, dpkg.Package{
+			Name:         c.Package,
+			Version:      c.Version,
+			Architecture: c.Architecture,
+			Depends:      c.Depends,
+			Provides:     c.Fields["Provides"],
+		})
+	}
+
+	installed, err := dpkg.ReadStatus(root)
+	if err != nil {
+		return fmt.Errorf("Error reading dpkg status: %v, -skip-preflight installs without checking dependencies", err)
+	}
+
+	for _, d := range dpkg.Downgrades(packages, installed) {
+		fmt.Printf("Warning: %v, dpkg will downgrade it\n", d)
+	}
+
+	unsatisfied, err := dpkg.CheckDepends(packages, installed)
+	if err != nil {
+		return err
+	} else if len(unsatisfied) == 0 {
+		return nil
+	}
+
+	host := "this host"
+	if d, err := system.ReadDistribution(root); err == nil {
+		host = d.String()
+	}
+	lines := make([]string, 0, len(unsatisfied))
+	for _, u := range unsatisfied {
+		lines = append(lines, "  "+u.String())
+	}
+	return fmt.Errorf("Dependencies which can't be satisfied on %s, nothing was installed:\n%s\n-skip-preflight installs anyway", host, strings.Join(lines, "\n"))
+}
@@ -1,37 +1,213 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/pmalek/kernel_deb_downloader/bytesize"
+	"github.com/pmalek/kernel_deb_downloader/cache"
+	"github.com/pmalek/kernel_deb_downloader/download"
+	"github.com/pmalek/kernel_deb_downloader/http"
 	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
 )
 
 var (
 	onlyPrintVersion bool
 	showChanges      bool
+	release          string
+	pin              string
+	channelName      string
+	archNames        string
+	flavour          string
+	listFlavours     bool
+	baseURL          string
+	skipFailedBuilds bool
+	requireBootTest  bool
+	showBuildLog     bool
+	skipChecksums    bool
+	keyringPath      string
+	skipSignature    bool
+	outputDir        string
+	versionDirs      bool
+	workers          int
+	timeout          time.Duration
+	retries          int
+	retryBackoff     time.Duration
+	cacheDir         string
+	noCache          bool
+	cacheMaxSize     = cache.DefaultMaxSize
+	rateLimit        bytesize.Size
+	fileRateLimit    bytesize.Size
+	progressMode     string
 )
 
+// hostArchErr is why -arch has no default, when no mainline
+// kernel .debs are published for the host's architecture
+var hostArchErr error
+
+// client is used for all requests, it supports ranged requests to resume
+// interrupted downloads, it's configured from flags in main
+var client = http.NewClient(nil)
+
+// baseURLEnv names the environment variable overriding
+// the default URL of the mainline kernel archive
+const baseURLEnv = "KERNEL_DEB_DOWNLOADER_URL"
+
+func defaultBaseURL() string {
+	if u := os.Getenv(baseURLEnv); u != "" {
+		return u
+	}
+	return ubuntukernelpageutils.KernelWebpage
+}
+
 func init() {
-	flag.BoolVar(&onlyPrintVersion, "n", false, "Print newest version - do not download the .debs")
+	flag.BoolVar(&onlyPrintVersion, "n", false, "Print selected version - do not download the .debs")
 	flag.BoolVar(&showChanges, "c", false, "Show changes included in particular kernel package")
+	flag.StringVar(&pin, "pin", "", "Exact version to download e.g. \"v6.8.2\", can't be combined with -release")
+	flag.StringVar(&channelName, "channel", "stable", "Releases to choose from: \"stable\", \"rc\" (releases and release candidates) or \"rc-only\"")
+	var hostArch string
+	hostArch, hostArchErr = ubuntukernelpageutils.HostArch()
+	flag.StringVar(&archNames, "arch", hostArch, "Comma separated architectures to download e.g. \"amd64,arm64\", each into its own directory when more than one is given")
+	flag.StringVar(&flavour, "flavour", "generic", "Kernel flavour to download e.g. \"generic\", \"lowlatency\" or \"generic-64k\"")
+	flag.BoolVar(&listFlavours, "flavours", false, "Print flavours published for the selected release - do not download the .debs")
+	flag.StringVar(&baseURL, "url", defaultBaseURL(), "URL of the mainline kernel archive, can also be set with "+baseURLEnv)
+	flag.StringVar(&release, "release", "latest", "Version constraint selecting the release e.g. \"~6.6\", \">=6.8 <6.10\", \"6.1.x\", \"latest\" or \"previous\"")
+	flag.BoolVar(&skipFailedBuilds, "skip-failed-builds", true, "Skip releases which failed to build for the selected architectures")
+	flag.BoolVar(&requireBootTest, "require-boot-test", false, "Skip releases which didn't pass boot tests for the selected architectures")
+	flag.BoolVar(&showBuildLog, "build-log", false, "Print an excerpt of the build log of each skipped release")
+	flag.BoolVar(&skipChecksums, "skip-checksums", false, "Don't verify downloaded .debs against the published CHECKSUMS")
+	flag.StringVar(&keyringPath, "keyring", "", "OpenPGP keyring CHECKSUMS have to be signed with, the Kernel PPA key "+ubuntukernelpageutils.KernelPPAKeyFingerprint+" fetched from "+ubuntukernelpageutils.KeyserverURL+" is used when not set")
+	flag.BoolVar(&skipSignature, "skip-signature", false, "Trust CHECKSUMS even when their signature is missing or bad")
+	flag.StringVar(&outputDir, "o", ".", "Directory to download the .debs to")
+	flag.IntVar(&workers, "workers", download.DefaultWorkers, "Number of .debs downloaded at once")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "Time after which a stalled request fails, 0 disables it")
+	flag.IntVar(&retries, "retries", http.DefaultRetryPolicy.MaxAttempts-1, "Number of times failed requests and interrupted downloads are retried")
+	flag.DurationVar(&retryBackoff, "retry-backoff", http.DefaultRetryPolicy.InitialBackoff, "Delay before the first retry, doubled with each next one")
+	defaultCacheDir, _ := cache.DefaultDir()
+	flag.StringVar(&cacheDir, "cache-dir", defaultCacheDir, "Directory verified .debs are cached in across runs")
+	flag.BoolVar(&noCache, "no-cache", false, "Don't use the download cache")
+	flag.Var(&cacheMaxSize, "cache-max-size", "Maximum `size` of the download cache e.g. \"500M\", least recently used .debs are removed first, 0 means unlimited")
+	flag.Var(&rateLimit, "limit-rate", "Download all .debs together at most at `rate` bytes per second e.g. \"2M\", 0 means unlimited")
+	flag.Var(&fileRateLimit, "limit-rate-file", "Download each .deb at most at `rate` bytes per second e.g. \"500K\", 0 means unlimited")
+	flag.StringVar(&progressMode, "progress", "auto", "How download progress is shown: \"bars\", \"plain\" (periodic log lines), \"json\" (JSON lines on stderr), \"none\" or \"auto\" (bars on terminals, plain otherwise)")
+	flag.BoolVar(&versionDirs, "version-dirs", false, "Download each release into its own subdirectory of -o and point the \"latest\" symlink to it")
+
+	flag.Usage = func() {
+		name := filepath.Base(os.Args[0])
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage of %s:\n", name)
+		fmt.Fprintf(out, "  %s [flags]\n    \tDownload the selected release\n", name)
+		fmt.Fprintf(out, "  %s list [flags]\n    \tList available releases\n", name)
+		fmt.Fprintf(out, "  %s [flags] check [flags]\n    \tCheck whether the selected release is newer than the running kernel\n", name)
+		fmt.Fprintf(out, "  %s install [flags] [directory]\n    \tInstall the release downloaded into directory with dpkg\n", name)
+		fmt.Fprintf(out, "  %s inspect [flags] [file or directory...]\n    \tPrint the control fields of .debs and verify their files against their md5sums\n", name)
+		fmt.Fprintf(out, "  %s clean [flags]\n    \tRemove installed mainline kernels except the newest, running and pinned ones\n", name)
+		fmt.Fprintf(out, "  %s cache prune [flags]\n    \tRemove least recently used .debs from the download cache\n", name)
+		fmt.Fprintln(out, "\nFlags:")
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
-	flag.Parse()
+	// check reports invalid flags with its own exit code
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		command := subcommand(os.Args[1:])
+		if err == flag.ErrHelp && command != "check" {
+			os.Exit(0)
+		}
+		os.Exit(exitUsage(command))
+	}
+
+	client.Timeout = timeout
+	client.Retry = http.RetryPolicy{
+		MaxAttempts:    retries + 1,
+		InitialBackoff: retryBackoff,
+		MaxBackoff:     http.DefaultRetryPolicy.MaxBackoff,
+	}
 
-	version, packageURL, err := ubuntukernelpageutils.GetMostActualKernelVersion(http.DefaultClient)
+	// Ctrl-C stops in-flight requests, incomplete .debs are left as .part files
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch flag.Arg(0) {
+	case "", "check":
+	case "list":
+		os.Exit(runList(ctx, flag.Args()[1:]))
+	case "install":
+		os.Exit(runInstall(ctx, flag.Args()[1:]))
+	case "inspect":
+		os.Exit(runInspect(flag.Args()[1:]))
+	case "clean":
+		os.Exit(runClean(ctx, flag.Args()[1:]))
+	case "cache":
+		os.Exit(runCache(flag.Args()[1:]))
+	default:
+		fmt.Printf("Unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if pin != "" && flagSet("release") {
+		fmt.Println("-pin and -release can't be used together")
+		os.Exit(exitUsage(flag.Arg(0)))
+	}
+
+	channel, err := ubuntukernelpageutils.ParseChannel(channelName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitUsage(flag.Arg(0)))
+	}
+
+	// archs is nil when the host's architecture isn't published, which
+	// only matters once .debs are downloaded or releases filtered by it
+	archs, err := selectedArchs()
+	if err != nil && (flagSet("arch") || archsNeeded()) {
+		fmt.Println(err)
+		os.Exit(exitUsage(flag.Arg(0)))
+	}
+
+	progress, err := newProgress(progressMode)
 	if err != nil {
-		fmt.Printf("Error connecting to Ubuntu's kernel ppa webpage, error: %q", err)
+		fmt.Println(err)
+		os.Exit(exitUsage(flag.Arg(0)))
+	}
+
+	if flag.Arg(0) == "check" {
+		os.Exit(runCheck(ctx, flag.Args()[1:], channel, archs))
+	}
+
+	version, packageURL, err := resolveVersion(ctx, channel, archs)
+	if err != nil {
+		exitIfInterrupted(ctx)
+		fmt.Printf("Error resolving kernel version from Ubuntu's kernel ppa webpage, error: %q", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Most recent (non RC) version: %v, link: %v\n", version, packageURL)
+	switch {
+	case pin != "":
+		fmt.Printf("Pinned version: %v, link: %v\n", version, packageURL)
+	case release == "latest" && channel == ubuntukernelpageutils.ChannelStable:
+		fmt.Printf("Most recent (non RC) version: %v, link: %v\n", version, packageURL)
+	case release == "latest":
+		fmt.Printf("Most recent %v version: %v, link: %v\n", channel, version, packageURL)
+	default:
+		fmt.Printf("Version matching %q: %v, link: %v\n", release, version, packageURL)
+	}
 
 	if showChanges {
-		if changes, err := ubuntukernelpageutils.GetChangesFromPackageURL(http.DefaultClient, packageURL); err != nil {
+		if changes, err := ubuntukernelpageutils.GetChangesFromPackageURL(ctx, client, packageURL); err != nil {
+			exitIfInterrupted(ctx)
 			fmt.Printf("Error downloading changes: %v", err.Error())
 			os.Exit(1)
 		} else {
@@ -39,11 +215,267 @@ func main() {
 		}
 	}
 
+	if listFlavours {
+		packages, err := ubuntukernelpageutils.ListPackages(ctx, client, packageURL)
+		if err != nil {
+			exitIfInterrupted(ctx)
+			fmt.Printf("Error listing packages: %q\n", err)
+			os.Exit(1)
+		}
+
+		for _, arch := range ubuntukernelpageutils.Archs(packages) {
+			fmt.Printf("%s: %s\n", arch, strings.Join(ubuntukernelpageutils.Flavours(packages, arch), ", "))
+		}
+		return
+	}
+
 	if onlyPrintVersion == false {
-		_, err = ubuntukernelpageutils.DownloadKernelDebs(http.DefaultClient, packageURL)
+		keyring, err := loadKeyring(ctx)
 		if err != nil {
-			fmt.Printf("Error downloading .deb files: %q", err)
+			exitIfInterrupted(ctx)
+			fmt.Printf("Error loading keyring: %v\n", err)
+			os.Exit(1)
+		}
+
+		dir := outputDir
+		if versionDirs {
+			dir = filepath.Join(outputDir, version.String())
+		}
+
+		failed := false
+		for _, arch := range archs {
+			if ctx.Err() != nil {
+				break
+			}
+
+			opts := ubuntukernelpageutils.DownloadOptions{
+				Arch:          arch,
+				Flavour:       flavour,
+				Dir:           dir,
+				SkipChecksums: skipChecksums,
+				Keyring:       keyring,
+				SkipSignature: skipSignature,
+				Workers:       workers,
+				Retry:         client.Retry,
+				RateLimit:     rateLimit,
+				FileRateLimit: fileRateLimit,
+				Progress:      progress,
+			}
+			if !noCache && cacheDir != "" {
+				opts.Cache = cache.New(cacheDir, cacheMaxSize)
+			}
+			if len(archs) > 1 {
+				opts.Dir = filepath.Join(dir, arch)
+			}
+
+			results, err := ubuntukernelpageutils.DownloadKernelDebs(ctx, client, packageURL, opts)
+			if _, partial := err.(*download.Error); err != nil && !partial {
+				fmt.Printf("Error downloading %s .deb files: %q\n", arch, err)
+				failed = true
+				continue
+			}
+
+			failed = printResults(arch, results) || failed
+		}
+
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted, incomplete .debs were left in %s as .part files, run again to resume\n", dir)
+			os.Exit(exitInterrupted)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+
+		if versionDirs {
+			if err := download.LinkLatest(outputDir, version.String()); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+	}
+
+}
+
+// flagSet returns whether the flag @name was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// selectedArchs returns the architectures selected with -arch, when it
+// isn't given on a host no .debs are published for it returns the reason
+func selectedArchs() ([]string, error) {
+	if hostArchErr != nil && !flagSet("arch") {
+		return nil, fmt.Errorf("%v, pass -arch", hostArchErr)
+	}
+	return ubuntukernelpageutils.ParseArchs(archNames)
+}
+
+// archsNeeded returns whether the selected flags download .debs or filter
+// releases by architecture. Failed builds are only skipped by default
+// when the architectures are known.
+func archsNeeded() bool {
+	downloads := flag.Arg(0) == "" && !onlyPrintVersion && !listFlavours
+	return downloads || requireBootTest || (skipFailedBuilds && flagSet("skip-failed-builds"))
+}
+
+// resolveVersion returns the version selected with -pin or -release
+// from @channel, built for @archs, and the URL it's published at
+func resolveVersion(ctx context.Context, channel ubuntukernelpageutils.Channel, archs []string) (versionutils.KernelVersion, string, error) {
+	switch {
+	case pin != "":
+		return ubuntukernelpageutils.GetKernelVersion(ctx, client, baseURL, pin)
+	case requireBootTest || (skipFailedBuilds && len(archs) > 0):
+		r, err := ubuntukernelpageutils.ResolveBuiltKernelVersion(ctx, client, baseURL, release, channel,
+			ubuntukernelpageutils.BuildRequirements{Archs: archs, BootTest: requireBootTest, OnSkip: skipPrinter(ctx)})
+		return r.Version, r.URL, err
+	default:
+		return ubuntukernelpageutils.ResolveKernelVersion(ctx, client, baseURL, release, channel)
+	}
+}
+
+// exitUsage returns the exit code for invalid flags given with the
+// @command subcommand: checkError for check, so that scripts only have
+// to handle its exit codes, 2 otherwise
+func exitUsage(command string) int {
+	if command == "check" {
+		return checkError
+	}
+	return 2
+}
+
+// subcommand returns the subcommand of the command line arguments @args
+// when parsing them failed and flag.Arg(0) isn't set: the first argument
+// which is neither a global flag nor the value of one
+func subcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		case len(arg) < 2 || arg[0] != '-':
+			return arg
+		case strings.Contains(arg, "="):
+			continue
+		}
+
+		f := flag.Lookup(strings.TrimLeft(arg, "-"))
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		// the flag's value is the next argument
+		i++
+	}
+	return ""
+}
+
+// exitInterrupted is the exit code after SIGINT or SIGTERM
+const exitInterrupted = 130
+
+// exitIfInterrupted exits when @ctx was canceled by SIGINT or SIGTERM
+func exitIfInterrupted(ctx context.Context) {
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted")
+		os.Exit(exitInterrupted)
+	}
+}
+
+const (
+	// plainProgressInterval is how often -progress plain logs each .deb
+	plainProgressInterval = 10 * time.Second
+	// jsonProgressInterval is how often -progress json reports each .deb
+	jsonProgressInterval = time.Second
+)
+
+// newProgress returns the download.Progress selected with -progress @mode,
+// plain and JSON progress is written to stderr to keep stdout for results
+func newProgress(mode string) (download.Progress, error) {
+	switch mode {
+	case "auto":
+		if isTerminal(os.Stdout) {
+			return download.NewBarsProgress(), nil
+		}
+		return download.NewPlainProgress(os.Stderr, plainProgressInterval), nil
+	case "bars":
+		return download.NewBarsProgress(), nil
+	case "plain":
+		return download.NewPlainProgress(os.Stderr, plainProgressInterval), nil
+	case "json":
+		return download.NewJSONProgress(os.Stderr, jsonProgressInterval), nil
+	case "none":
+		return download.Silent, nil
+	default:
+		return nil, fmt.Errorf("Invalid progress %q, expected \"auto\", \"bars\", \"plain\", \"json\" or \"none\"", mode)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// skipPrinter returns a BuildRequirements.OnSkip callback reporting
+// releases being skipped, build logs are downloaded using @ctx
+func skipPrinter(ctx context.Context) func(ubuntukernelpageutils.Release, string, ubuntukernelpageutils.BuildStatus) {
+	return func(r ubuntukernelpageutils.Release, arch string, status ubuntukernelpageutils.BuildStatus) {
+		if !status.Built {
+			fmt.Printf("Skipping %v: build for %s failed\n", r.Version, arch)
+		} else {
+			fmt.Printf("Skipping %v: boot test for %s didn't pass\n", r.Version, arch)
+		}
+
+		if showBuildLog && status.LogURL != "" {
+			excerpt, err := ubuntukernelpageutils.GetBuildLogExcerpt(ctx, client, status, 20)
+			if err != nil {
+				fmt.Printf("Error downloading build log: %v\n", err)
+				return
+			}
+			fmt.Printf("Build log %s:\n%s\n", status.LogURL, excerpt)
+		}
+	}
+}
+
+// loadKeyring returns the keyring CHECKSUMS have to be signed with
+// or nil when signatures aren't verified
+func loadKeyring(ctx context.Context) (openpgp.EntityList, error) {
+	switch {
+	case skipChecksums || skipSignature:
+		return nil, nil
+	case keyringPath != "":
+		return ubuntukernelpageutils.LoadKeyring(keyringPath)
+	default:
+		return ubuntukernelpageutils.GetKernelPPAKey(ctx, client, ubuntukernelpageutils.KeyserverURL)
+	}
+}
+
+// printResults reports the outcome of downloading each of @arch's .debs
+// returning whether any of them failed
+func printResults(arch string, results []download.Result) (failed bool) {
+	downloaded := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("Error downloading %s: %v\n", r.URL, r.Err)
+			failed = true
+			continue
+		case r.Cached:
+			fmt.Printf("Copied %s from cache (%d bytes, %s verified)\n", r.FileName, r.Size, r.Checksum.Algorithm)
+		case r.Verified:
+			fmt.Printf("Downloaded %s (%d bytes in %v, %s verified)\n", r.FileName, r.Size, r.Duration.Round(time.Millisecond), r.Checksum.Algorithm)
+		default:
+			fmt.Printf("Downloaded %s (%d bytes in %v)\n", r.FileName, r.Size, r.Duration.Round(time.Millisecond))
 		}
+		downloaded++
 	}
 
+	fmt.Printf("Downloaded %d of %d %s .deb files\n", downloaded, len(results), arch)
+	return failed
 }
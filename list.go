@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pmalek/kernel_deb_downloader/ubuntukernelpageutils"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+var regSeries = regexp.MustCompile(`^v?\d+(\.\d+)?$`)
+
+// listGlobalFlags are the global flags list uses when they're given
+// before it, the others are rejected rather than silently ignored
+var listGlobalFlags = map[string]bool{"channel": true, "url": true, "timeout": true, "retries": true, "retry-backoff": true}
+
+// runList implements the list subcommand which prints every release
+// published on Ubuntu's kernel ppa. It returns the process exit code.
+func runList(ctx context.Context, args []string) int {
+	var ignored []string
+	flag.Visit(func(f *flag.Flag) {
+		if !listGlobalFlags[f.Name] {
+			ignored = append(ignored, "-"+f.Name)
+		}
+	})
+	if len(ignored) > 0 {
+		fmt.Printf("%s can't be used with list\n", strings.Join(ignored, ", "))
+		return 2
+	}
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	channelName := fs.String("channel", channelName, "Releases to list: \"stable\", \"rc\" (releases and release candidates) or \"rc-only\"")
+	series := fs.String("series", "", "List only releases from a major or major.minor series e.g. \"6\" or \"6.8\"")
+	limit := fs.Int("limit", 0, "List only the newest N releases, 0 lists all of them")
+	baseURL := fs.String("url", baseURL, "URL of the mainline kernel archive, can also be set with "+baseURLEnv)
+	fs.Parse(args)
+
+	channel, err := ubuntukernelpageutils.ParseChannel(*channelName)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	constraint := versionutils.Constraint{}
+	if *series != "" {
+		if !regSeries.MatchString(*series) {
+			fmt.Printf("Invalid series %q, expected a major or major.minor version e.g. \"6\" or \"6.8\"\n", *series)
+			return 2
+		}
+		if constraint, err = versionutils.ParseConstraint(*series + ".x"); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+
+	releases, err := ubuntukernelpageutils.ListReleases(ctx, client, *baseURL, channel)
+	if err != nil {
+		fmt.Printf("Error listing releases from Ubuntu's kernel ppa webpage, error: %q\n", err)
+		return 1
+	}
+
+	var matching []ubuntukernelpageutils.Release
+	for _, r := range releases {
+		if constraint.Check(r.Version) {
+			matching = append(matching, r)
+		}
+	}
+	if *limit > 0 && len(matching) > *limit {
+		matching = matching[len(matching)-*limit:]
+	}
+
+	printReleases(matching)
+	return 0
+}
+
+func printReleases(releases []ubuntukernelpageutils.Release) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tLAST MODIFIED\tRC\tURL")
+
+	for _, r := range releases {
+		lastModified := "-"
+		if !r.LastModified.IsZero() {
+			lastModified = r.LastModified.Format("2006-01-02 15:04")
+		}
+
+		rc := "no"
+		if r.Version.IsRC() {
+			rc = "yes"
+		}
+
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", r.Version, lastModified, rc, r.URL)
+	}
+
+	w.Flush()
+}
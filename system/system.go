@@ -0,0 +1,113 @@
+// Package system inspects the machine kernels are installed on. Paths
+// are resolved under a root directory, "/" for the running system,
+// so that it can be pointed at a fake one e.g. in tests.
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OSReleasePath is the file holding the running kernel's release
+const OSReleasePath = "proc/sys/kernel/osrelease"
+
+// uname returns the running kernel's release printed by uname -r
+var uname = func() ([]byte, error) {
+	return exec.Command("uname", "-r").Output()
+}
+
+// KernelRelease returns the release of the kernel running on the system
+// at @root e.g. "6.8.2-060802-generic", the same as uname -r prints.
+// When OSReleasePath is missing, e.g. without /proc mounted in a
+// container, uname -r is run instead for the running system's root.
+func KernelRelease(root string) (string, error) {
+	return kernelRelease(root, filepath.Clean(root) == "/")
+}
+
+// kernelRelease implements KernelRelease, running uname -r
+// only when @root is the @running system's
+func kernelRelease(root string, running bool) (string, error) {
+	path := filepath.Join(root, OSReleasePath)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && running {
+		path = "uname -r output"
+		if data, err = uname(); err != nil {
+			return "", fmt.Errorf("Could not read the running kernel's release from %s nor uname -r: %v", OSReleasePath, err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("Could not read the running kernel's release: %v", err)
+	}
+
+	release := strings.TrimSpace(string(data))
+	if release == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return release, nil
+}
+
+// OSReleasePaths are the files identifying the distribution, in the
+// order they're looked up in, see os-release(5)
+var OSReleasePaths = []string{"etc/os-release", "usr/lib/os-release"}
+
+// Distribution identifies the distribution installed on the system
+type Distribution struct {
+	// ID is e.g. "ubuntu"
+	ID string
+	// VersionID is e.g. "22.04"
+	VersionID string
+	// PrettyName is e.g. "Ubuntu 22.04.4 LTS"
+	PrettyName string
+}
+
+// ReadDistribution reads the os-release file of the system at @root
+func ReadDistribution(root string) (Distribution, error) {
+	var (
+		data []byte
+		err  error
+	)
+	for _, path := range OSReleasePaths {
+		if data, err = ioutil.ReadFile(filepath.Join(root, path)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return Distribution{}, fmt.Errorf("Could not read os-release: %v", err)
+	}
+
+	var d Distribution
+	for _, line := range strings.Split(string(data), "\n") {
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		value := strings.TrimSpace(line[eq+1:])
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "'")
+		}
+
+		switch strings.TrimSpace(line[:eq]) {
+		case "ID":
+			d.ID = value
+		case "VERSION_ID":
+			d.VersionID = value
+		case "PRETTY_NAME":
+			d.PrettyName = value
+		}
+	}
+	return d, nil
+}
+
+func (d Distribution) String() string {
+	if d.PrettyName != "" {
+		return d.PrettyName
+	}
+	return strings.TrimSpace(d.ID + " " + d.VersionID)
+}
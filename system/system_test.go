@@ -0,0 +1,108 @@
+package system
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeRoot returns a root directory with @files written relative to it
+func fakeRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func Test_KernelRelease(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		expected string
+		err      bool
+	}{
+		{map[string]string{OSReleasePath: "6.8.2-060802-generic\n"}, "6.8.2-060802-generic", false},
+		{map[string]string{OSReleasePath: "\n"}, "", true},
+		{map[string]string{}, "", true},
+	}
+
+	for _, tt := range tests {
+		actual, err := KernelRelease(fakeRoot(t, tt.files))
+		if (err != nil) != tt.err || actual != tt.expected {
+			t.Errorf("KernelRelease(%v)\nExpected: %q, error %t,\nactual %q, %v", tt.files, tt.expected, tt.err, actual, err)
+		}
+	}
+}
+
+func Test_kernelRelease_Uname(t *testing.T) {
+	defer func(orig func() ([]byte, error)) { uname = orig }(uname)
+
+	uname = func() ([]byte, error) { return []byte("6.8.2-060802-generic\n"), nil }
+	if actual, err := kernelRelease(fakeRoot(t, nil), true); err != nil || actual != "6.8.2-060802-generic" {
+		t.Errorf("kernelRelease() without %s\nExpected: %q,\nactual %q, %v", OSReleasePath, "6.8.2-060802-generic", actual, err)
+	}
+	if _, err := kernelRelease(fakeRoot(t, nil), false); err == nil {
+		t.Errorf("kernelRelease() was supposed to ignore uname -r for another system's root")
+	}
+
+	root := fakeRoot(t, map[string]string{OSReleasePath: "6.9.0-060900-generic\n"})
+	if actual, err := kernelRelease(root, true); err != nil || actual != "6.9.0-060900-generic" {
+		t.Errorf("kernelRelease() was supposed to prefer %s, actual %q, %v", OSReleasePath, actual, err)
+	}
+
+	uname = func() ([]byte, error) { return nil, errors.New("uname: not found") }
+	if _, err := kernelRelease(fakeRoot(t, nil), true); err == nil {
+		t.Errorf("kernelRelease() was supposed to return an error when uname -r fails")
+	}
+}
+
+func Test_ReadDistribution(t *testing.T) {
+	osRelease := `PRETTY_NAME="Ubuntu 22.04.4 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+# a comment
+ID=ubuntu
+ID_LIKE=debian
+`
+
+	tests := []struct {
+		files    map[string]string
+		expected Distribution
+		err      bool
+	}{
+		{map[string]string{"etc/os-release": osRelease}, Distribution{ID: "ubuntu", VersionID: "22.04", PrettyName: "Ubuntu 22.04.4 LTS"}, false},
+		{map[string]string{"usr/lib/os-release": "ID=debian\nVERSION_ID='12'\n"}, Distribution{ID: "debian", VersionID: "12"}, false},
+		{map[string]string{}, Distribution{}, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ReadDistribution(fakeRoot(t, tt.files))
+		if (err != nil) != tt.err || actual != tt.expected {
+			t.Errorf("ReadDistribution(%v)\nExpected: %+v, error %t,\nactual %+v, %v", tt.files, tt.expected, tt.err, actual, err)
+		}
+	}
+}
+
+func Test_Distribution_String(t *testing.T) {
+	tests := []struct {
+		d        Distribution
+		expected string
+	}{
+		{Distribution{ID: "ubuntu", VersionID: "22.04", PrettyName: "Ubuntu 22.04.4 LTS"}, "Ubuntu 22.04.4 LTS"},
+		{Distribution{ID: "debian", VersionID: "12"}, "debian 12"},
+	}
+	for _, tt := range tests {
+		if actual := tt.d.String(); actual != tt.expected {
+			t.Errorf("%+v.String()\nExpected: %q,\nactual %q", tt.d, tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ubuntukernelpageutils
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// SupportedArchs lists Debian architectures for which
+// mainline kernel .debs are (or were) published
+var SupportedArchs = []string{"amd64", "arm64", "armhf", "ppc64el", "s390x", "i386"}
+
+// goArchToDebian maps GOARCH values to Debian architecture names
+var goArchToDebian = map[string]string{
+	"amd64":   "amd64",
+	"arm64":   "arm64",
+	"arm":     "armhf",
+	"ppc64le": "ppc64el",
+	"s390x":   "s390x",
+	"386":     "i386",
+}
+
+// HostArch returns the Debian architecture of the machine
+// kernel_deb_downloader is running on
+func HostArch() (string, error) {
+	if arch, ok := goArchToDebian[runtime.GOARCH]; ok {
+		return arch, nil
+	}
+	return "", fmt.Errorf("no mainline kernel .debs are published for %s", runtime.GOARCH)
+}
+
+// ParseArchs parses a comma separated list of Debian architectures
+// e.g. "amd64,arm64" and validates them against SupportedArchs
+func ParseArchs(s string) ([]string, error) {
+	var archs []string
+	encountered := map[string]bool{}
+
+	for _, arch := range strings.Split(s, ",") {
+		arch = strings.TrimSpace(arch)
+		if arch == "" || encountered[arch] {
+			continue
+		}
+		if !isSupportedArch(arch) {
+			return nil, fmt.Errorf("unsupported architecture %q, expected one of: %s", arch, strings.Join(SupportedArchs, ", "))
+		}
+
+		encountered[arch] = true
+		archs = append(archs, arch)
+	}
+
+	if len(archs) == 0 {
+		return nil, fmt.Errorf("no architecture given")
+	}
+	return archs, nil
+}
+
+func isSupportedArch(arch string) bool {
+	for _, a := range SupportedArchs {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
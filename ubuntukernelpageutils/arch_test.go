@@ -0,0 +1,46 @@
+package ubuntukernelpageutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+type parseArchsTestData struct {
+	input    string
+	expected []string
+	err      bool
+}
+
+func Test_ParseArchs(t *testing.T) {
+	tests := []parseArchsTestData{
+		{"amd64", []string{"amd64"}, false},
+		{"amd64,arm64", []string{"amd64", "arm64"}, false},
+		{" ppc64el , s390x,ppc64el", []string{"ppc64el", "s390x"}, false},
+		{"armhf,i386", []string{"armhf", "i386"}, false},
+		{"x86_64", nil, true},
+		{"amd64,mips", nil, true},
+		{"", nil, true},
+		{",", nil, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseArchs(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseArchs(%q): Expected error: %t, actual error: %v", tt.input, tt.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("ParseArchs(%q): Expected: %q, actual %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func Test_HostArch(t *testing.T) {
+	arch, err := HostArch()
+	if err != nil {
+		t.Skipf("HostArch() is not supported on this machine: %v", err)
+	}
+	if !isSupportedArch(arch) {
+		t.Errorf("HostArch() returned %q which is not in SupportedArchs", arch)
+	}
+}
@@ -0,0 +1,205 @@
+package ubuntukernelpageutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+
+	"golang.org/x/net/html"
+)
+
+// e.g. "Build for amd64 succeeded (see BUILD.LOG.amd64):"
+var regBuildResult = regexp.MustCompile(`Build for ([a-z0-9]+) (succeeded|failed)`)
+
+// e.g. "Test amd64/boot failed (rc=1, on=amd64, time=...)"
+var regTestResult = regexp.MustCompile(`Test ([a-z0-9]+)/([a-z0-9_-]+) (succeeded|failed|skipped)`)
+
+// e.g. BUILD.LOG.amd64 or amd64/log
+var regBuildLog = regexp.MustCompile(`^(?:BUILD\.LOG\.([a-z0-9]+)|([a-z0-9]+)/log)$`)
+
+// BuildStatus describes the outcome of building (and testing)
+// a release for a single architecture
+type BuildStatus struct {
+	Arch string
+	// Built is whether the .debs were built successfully
+	Built bool
+	// Tests maps names of tests run on the built kernel e.g. "boot"
+	// to whether they passed, skipped tests didn't pass
+	Tests map[string]bool
+	// LogURL points to the build log, empty if it isn't published
+	LogURL string
+}
+
+// Passed returns whether test @name was run and passed
+func (s BuildStatus) Passed(name string) bool {
+	return s.Tests[name]
+}
+
+// parseBuildStatus parses build and test results of a release page
+// at @packageURL, returning them per architecture
+func parseBuildStatus(respBody io.Reader, packageURL string) map[string]BuildStatus {
+	z := html.NewTokenizer(respBody)
+
+	var text strings.Builder
+	logs := map[string]string{}
+
+	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+		switch tt {
+		case html.TextToken:
+			text.Write(z.Text())
+		case html.StartTagToken:
+			for _, a := range z.Token().Attr {
+				if a.Key != "href" {
+					continue
+				}
+				if m := regBuildLog.FindStringSubmatch(a.Val); m != nil {
+					logs[m[1]+m[2]] = resolveLink(packageURL, a.Val)
+				}
+			}
+		}
+	}
+
+	statuses := map[string]BuildStatus{}
+	get := func(arch string) BuildStatus {
+		if s, ok := statuses[arch]; ok {
+			return s
+		}
+		return BuildStatus{Arch: arch, Built: true, Tests: map[string]bool{}, LogURL: logs[arch]}
+	}
+
+	for _, m := range regBuildResult.FindAllStringSubmatch(text.String(), -1) {
+		s := get(m[1])
+		s.Built = s.Built && m[2] == "succeeded"
+		statuses[m[1]] = s
+	}
+
+	for _, m := range regTestResult.FindAllStringSubmatch(text.String(), -1) {
+		s := get(m[1])
+		passed := m[3] == "succeeded"
+		if m[2] == "build" {
+			s.Built = s.Built && passed
+		} else if prev, ok := s.Tests[m[2]]; ok {
+			s.Tests[m[2]] = prev && passed
+		} else {
+			s.Tests[m[2]] = passed
+		}
+		statuses[m[1]] = s
+	}
+
+	return statuses
+}
+
+// GetBuildStatus returns build and test results of @release per
+// architecture. Architectures for which the release page doesn't
+// publish results are considered built when their .debs are published.
+func GetBuildStatus(ctx context.Context, client http.Getter, release Release) (map[string]BuildStatus, error) {
+	resp, err := client.Get(ctx, release.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Could get package webpage %s, received: %v", release.URL, err)
+	}
+	defer resp.Body.Close()
+
+	statuses := parseBuildStatus(resp.Body, release.URL)
+	if len(statuses) > 0 {
+		return statuses, nil
+	}
+
+	packages, err := ListPackages(ctx, client, release.URL)
+	if err != nil {
+		return nil, err
+	}
+	for _, arch := range Archs(packages) {
+		statuses[arch] = BuildStatus{Arch: arch, Built: true, Tests: map[string]bool{}}
+	}
+	return statuses, nil
+}
+
+// GetBuildLogExcerpt returns the last @lines lines of the build log
+// referenced by @status
+func GetBuildLogExcerpt(ctx context.Context, client http.Getter, status BuildStatus, lines int) (string, error) {
+	if status.LogURL == "" {
+		return "", fmt.Errorf("no build log is published for %s", status.Arch)
+	}
+
+	resp, err := client.Get(ctx, status.LogURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	logLines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(logLines) > lines {
+		logLines = logLines[len(logLines)-lines:]
+	}
+	return strings.Join(logLines, "\n"), nil
+}
+
+// BuildRequirements narrows down releases to the ones
+// that were built successfully for all of Archs
+type BuildRequirements struct {
+	Archs []string
+	// BootTest additionally requires boot tests to have passed
+	BootTest bool
+	// OnSkip, if set, is called for each release skipped because it
+	// didn't meet the requirements for @arch
+	OnSkip func(r Release, arch string, status BuildStatus)
+}
+
+func (req BuildRequirements) unmetArch(statuses map[string]BuildStatus) (string, BuildStatus, bool) {
+	for _, arch := range req.Archs {
+		s, ok := statuses[arch]
+		if !ok {
+			s = BuildStatus{Arch: arch}
+		}
+		if !s.Built || (req.BootTest && !s.Passed("boot")) {
+			return arch, s, true
+		}
+	}
+	return "", BuildStatus{}, false
+}
+
+// ResolveBuiltKernelVersion works like ResolveKernelVersion but skips
+// releases that didn't build (or pass boot tests) according to @req,
+// falling back to the next best release matching @constraint.
+// The returned release has its Builds populated.
+func ResolveBuiltKernelVersion(ctx context.Context, client http.Getter, baseURL, constraint string, channel Channel, req BuildRequirements) (Release, error) {
+	c, err := versionutils.ParseConstraint(constraint)
+	if err != nil {
+		return Release{}, err
+	}
+
+	releases, err := ListReleases(ctx, client, baseURL, channel)
+	if err != nil {
+		return Release{}, err
+	}
+
+	for _, v := range c.Candidates(releaseVersions(releases)) {
+		r, _ := findRelease(releases, v)
+
+		if r.Builds, err = GetBuildStatus(ctx, client, r); err != nil {
+			return Release{}, err
+		}
+
+		arch, status, unmet := req.unmetArch(r.Builds)
+		if !unmet {
+			return r, nil
+		}
+		if req.OnSkip != nil {
+			req.OnSkip(r, arch, status)
+		}
+	}
+
+	return Release{}, fmt.Errorf("No %v kernel version on %s matching %q was built for %s",
+		channel, baseURLOrDefault(baseURL), constraint, strings.Join(req.Archs, ", "))
+}
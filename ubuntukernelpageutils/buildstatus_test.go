@@ -0,0 +1,119 @@
+package ubuntukernelpageutils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+const releaseHeader682 = `<h2>Mainline build</h2><p>
+Build for amd64 succeeded (see <a href="BUILD.LOG.amd64">BUILD.LOG.amd64</a>):<br>
+Build for arm64 failed (see <a href="BUILD.LOG.arm64">BUILD.LOG.arm64</a>):<br>
+Test amd64/build succeeded (rc=0, on=amd64, time=20240327-1436)<br>
+Test amd64/boot failed (rc=1, on=amd64, time=20240327-1512)<br>
+Test arm64/build failed (rc=2, on=arm64, time=20240327-1436)<br>
+Test ppc64el/build succeeded (rc=0, on=ppc64el, time=20240327-1436)<br>
+Test ppc64el/boot skipped<br>
+</p>`
+
+func Test_parseBuildStatus(t *testing.T) {
+	const packageURL = "https://kernel.ubuntu.com/mainline/v6.8.2/"
+
+	expected := map[string]BuildStatus{
+		"amd64":   {Arch: "amd64", Built: true, Tests: map[string]bool{"boot": false}, LogURL: packageURL + "BUILD.LOG.amd64"},
+		"arm64":   {Arch: "arm64", Built: false, Tests: map[string]bool{}, LogURL: packageURL + "BUILD.LOG.arm64"},
+		"ppc64el": {Arch: "ppc64el", Built: true, Tests: map[string]bool{"boot": false}},
+	}
+
+	actual := parseBuildStatus(strings.NewReader(releaseHeader682), packageURL)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("parseBuildStatus()\nExpected: %+v,\nactual %+v", expected, actual)
+	}
+}
+
+func Test_parseBuildStatus_NoResults(t *testing.T) {
+	if actual := parseBuildStatus(strings.NewReader(packagePage682), ""); len(actual) != 0 {
+		t.Errorf("parseBuildStatus() was supposed to return no results but returned %+v", actual)
+	}
+}
+
+func Test_GetBuildStatus_FallsBackToPublishedPackages(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(packagePage682)
+
+	statuses, err := GetBuildStatus(context.Background(), client, Release{URL: "https://kernel.ubuntu.com/mainline/v6.8.2/"})
+	if err != nil {
+		t.Fatalf("GetBuildStatus() returned an unexpected error: %v", err)
+	}
+
+	for _, arch := range []string{"amd64", "arm64"} {
+		if !statuses[arch].Built {
+			t.Errorf("GetBuildStatus() was supposed to consider %s built", arch)
+		}
+	}
+	if _, ok := statuses["s390x"]; ok {
+		t.Errorf("GetBuildStatus() returned a status for s390x which has no packages")
+	}
+}
+
+func Test_GetBuildLogExcerpt(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetURLResponse("https://kernel.ubuntu.com/mainline/v6.8.2/BUILD.LOG.arm64", "line 1\nline 2\nline 3\nerror: build failed\n")
+
+	excerpt, err := GetBuildLogExcerpt(context.Background(), client, BuildStatus{Arch: "arm64", LogURL: "https://kernel.ubuntu.com/mainline/v6.8.2/BUILD.LOG.arm64"}, 2)
+	if err != nil {
+		t.Fatalf("GetBuildLogExcerpt() returned an unexpected error: %v", err)
+	}
+	if expected := "line 3\nerror: build failed"; excerpt != expected {
+		t.Errorf("GetBuildLogExcerpt()\nExpected: %q,\nactual %q", expected, excerpt)
+	}
+
+	if _, err := GetBuildLogExcerpt(context.Background(), client, BuildStatus{Arch: "arm64"}, 2); err == nil {
+		t.Errorf("GetBuildLogExcerpt() was supposed to return an error without a LogURL but it returned nil")
+	}
+}
+
+func Test_ResolveBuiltKernelVersion(t *testing.T) {
+	const baseURL = "https://kernel.ubuntu.com/mainline/"
+
+	client := http.MockedClient{}
+	client.SetURLResponse(baseURL, `<a href="v6.8.1/">v6.8.1/</a><a href="v6.8.2/">v6.8.2/</a><a href="v6.9/">v6.9/</a>`)
+	client.SetURLResponse(baseURL+"v6.9/", `Build for amd64 failed (see <a href="BUILD.LOG.amd64">BUILD.LOG.amd64</a>)`)
+	client.SetURLResponse(baseURL+"v6.8.2/", releaseHeader682)
+	client.SetURLResponse(baseURL+"v6.8.1/", `Test amd64/build succeeded<br>Test amd64/boot succeeded`)
+
+	tests := []struct {
+		req      BuildRequirements
+		expected string
+		skipped  []string
+	}{
+		{BuildRequirements{Archs: []string{"amd64"}}, "v6.8.2", []string{"v6.9 amd64"}},
+		{BuildRequirements{Archs: []string{"amd64"}, BootTest: true}, "v6.8.1", []string{"v6.9 amd64", "v6.8.2 amd64"}},
+		{BuildRequirements{Archs: []string{"amd64", "arm64"}}, "", []string{"v6.9 amd64", "v6.8.2 arm64", "v6.8.1 arm64"}},
+	}
+
+	for _, tt := range tests {
+		var skipped []string
+		tt.req.OnSkip = func(r Release, arch string, status BuildStatus) {
+			skipped = append(skipped, r.Version.String()+" "+arch)
+		}
+
+		r, err := ResolveBuiltKernelVersion(context.Background(), client, baseURL, "latest", ChannelStable, tt.req)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("ResolveBuiltKernelVersion(%v) was supposed to return an error but returned %v", tt.req.Archs, r.Version)
+			}
+		} else if err != nil || r.Version.String() != tt.expected {
+			t.Errorf("ResolveBuiltKernelVersion(%v): Expected: %s, actual %v, %v", tt.req.Archs, tt.expected, r.Version, err)
+		} else if r.Builds == nil {
+			t.Errorf("ResolveBuiltKernelVersion(%v) didn't populate Builds", tt.req.Archs)
+		}
+
+		if !reflect.DeepEqual(skipped, tt.skipped) {
+			t.Errorf("ResolveBuiltKernelVersion(%v) skipped\nExpected: %q,\nactual %q", tt.req.Archs, tt.skipped, skipped)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ubuntukernelpageutils
+
+import (
+	"fmt"
+
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// Channel selects which kinds of releases are taken into account
+// when looking for kernel versions
+type Channel int
+
+const (
+	// ChannelStable contains only final releases
+	ChannelStable Channel = iota
+	// ChannelWithRC contains both final releases and release candidates
+	ChannelWithRC
+	// ChannelRCOnly contains only release candidates
+	ChannelRCOnly
+)
+
+var channelNames = map[Channel]string{
+	ChannelStable: "stable",
+	ChannelWithRC: "rc",
+	ChannelRCOnly: "rc-only",
+}
+
+// ParseChannel returns the Channel named @s, one of
+// "stable", "rc" (releases and release candidates) or "rc-only"
+func ParseChannel(s string) (Channel, error) {
+	for c, name := range channelNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return ChannelStable, fmt.Errorf("unknown channel %q, expected one of: stable, rc, rc-only", s)
+}
+
+func (c Channel) String() string {
+	if name, ok := channelNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Channel(%d)", int(c))
+}
+
+// Contains returns whether version @v is published in channel @c
+func (c Channel) Contains(v versionutils.KernelVersion) bool {
+	switch c {
+	case ChannelWithRC:
+		return true
+	case ChannelRCOnly:
+		return v.IsRC()
+	}
+	return !v.IsRC()
+}
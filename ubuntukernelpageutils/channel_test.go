@@ -0,0 +1,39 @@
+package ubuntukernelpageutils
+
+import "testing"
+
+func Test_ParseChannel(t *testing.T) {
+	for _, c := range []Channel{ChannelStable, ChannelWithRC, ChannelRCOnly} {
+		actual, err := ParseChannel(c.String())
+		if err != nil || actual != c {
+			t.Errorf("ParseChannel(%q) returned %v, %v", c.String(), actual, err)
+		}
+	}
+
+	if _, err := ParseChannel("beta"); err == nil {
+		t.Errorf("ParseChannel(%q) was supposed to return an error but it returned nil", "beta")
+	}
+}
+
+type channelContainsTestData struct {
+	channel  Channel
+	version  string
+	expected bool
+}
+
+func Test_Channel_Contains(t *testing.T) {
+	tests := []channelContainsTestData{
+		{ChannelStable, "v6.9", true},
+		{ChannelStable, "v6.9-rc7", false},
+		{ChannelWithRC, "v6.9", true},
+		{ChannelWithRC, "v6.9-rc7", true},
+		{ChannelRCOnly, "v6.9", false},
+		{ChannelRCOnly, "v6.9-rc7", true},
+	}
+
+	for _, tt := range tests {
+		if actual := tt.channel.Contains(mustParse(tt.version)); actual != tt.expected {
+			t.Errorf("%v.Contains(%q): Expected: %t, actual %t", tt.channel, tt.version, tt.expected, actual)
+		}
+	}
+}
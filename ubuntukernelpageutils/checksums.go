@@ -0,0 +1,107 @@
+package ubuntukernelpageutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/download"
+	"github.com/pmalek/kernel_deb_downloader/http"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// parseChecksums parses a CHECKSUMS file as published next to the .debs
+// e.g. "<sha256>  linux-headers-..._all.deb" returning checksums per file
+// name. sha256 checksums are preferred over sha1 ones.
+func parseChecksums(r io.Reader) (map[string]download.Checksum, error) {
+	checksums := map[string]download.Checksum{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid CHECKSUMS line %q", line)
+		}
+
+		checksum, err := download.ChecksumFromHex(fields[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// shasum marks binary files with a leading asterisk
+		fileName := path.Base(strings.TrimPrefix(fields[1], "*"))
+		if prev, ok := checksums[fileName]; ok && prev.Algorithm == download.SHA256 {
+			continue
+		}
+		checksums[fileName] = checksum
+	}
+
+	return checksums, scanner.Err()
+}
+
+// GetChecksums fetches and parses the CHECKSUMS file published in the
+// directory at @dirURL. Unless @keyring is nil CHECKSUMS is trusted only
+// when CHECKSUMS.gpg is its valid signature made by a key from @keyring,
+// a *SignatureError is returned otherwise.
+func GetChecksums(ctx context.Context, client http.Getter, dirURL string, keyring openpgp.EntityList) (map[string]download.Checksum, error) {
+	checksumsURL := resolveLink(dirURL, "CHECKSUMS")
+
+	checksums, err := getFile(ctx, client, checksumsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring != nil {
+		signatureURL := checksumsURL + ".gpg"
+
+		signature, err := getFile(ctx, client, signatureURL)
+		if err != nil {
+			return nil, &SignatureError{URL: signatureURL, Err: err}
+		}
+		if _, err := verifySignature(keyring, checksums, signature); err != nil {
+			return nil, &SignatureError{URL: signatureURL, Err: err}
+		}
+	}
+
+	return parseChecksums(bytes.NewReader(checksums))
+}
+
+// getPackagesChecksums fetches checksums of @packages published at
+// @packageURL, from CHECKSUMS of each directory they're published in
+// verified with @keyring (unless it's nil)
+func getPackagesChecksums(ctx context.Context, client http.Getter, packageURL string, packages []Package, keyring openpgp.EntityList) (map[string]download.Checksum, error) {
+	checksums := map[string]download.Checksum{}
+	fetched := map[string]bool{}
+
+	for _, p := range packages {
+		if fetched[p.Subdir] {
+			continue
+		}
+		fetched[p.Subdir] = true
+
+		dirURL := packageURL
+		if p.Subdir != "" {
+			dirURL = resolveLink(packageURL, p.Subdir+"/")
+		}
+
+		dirChecksums, err := GetChecksums(ctx, client, dirURL, keyring)
+		if err != nil {
+			return nil, err
+		}
+		for fileName, c := range dirChecksums {
+			checksums[fileName] = c
+		}
+	}
+
+	return checksums, nil
+}
@@ -0,0 +1,77 @@
+package ubuntukernelpageutils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/download"
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+const checksums682 = `# Checksums, check with the command below:
+#     shasum -c CHECKSUMS
+#
+# Checksums-Sha1:
+f2f8d2a25a3e9c5ea15d3ef8e8c1f3ebbcbbf4a4  amd64/linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb
+0c4e2e3a9bb8d9e3d7d0d1b84e6f5a4c3b2a1908  amd64/linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb
+#
+# Checksums-Sha256:
+5d41402abc4b2a76b9719d911017c592ae2b1a6a1dc4a1d34e1f67bd5bc5e5b1  amd64/linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb
+`
+
+func Test_parseChecksums(t *testing.T) {
+	expected := map[string]download.Checksum{
+		"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb": {
+			Algorithm: download.SHA256, Sum: "5d41402abc4b2a76b9719d911017c592ae2b1a6a1dc4a1d34e1f67bd5bc5e5b1"},
+		"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb": {
+			Algorithm: download.SHA1, Sum: "0c4e2e3a9bb8d9e3d7d0d1b84e6f5a4c3b2a1908"},
+	}
+
+	actual, err := parseChecksums(strings.NewReader(checksums682))
+	if err != nil {
+		t.Fatalf("parseChecksums() returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("parseChecksums()\nExpected: %v,\nactual %v", expected, actual)
+	}
+}
+
+func Test_parseChecksums_Invalid(t *testing.T) {
+	for _, s := range []string{"abc  a.deb", "0c4e2e3a9bb8d9e3d7d0d1b84e6f5a4c3b2a1908"} {
+		if _, err := parseChecksums(strings.NewReader(s)); err == nil {
+			t.Errorf("parseChecksums(%q) was supposed to return an error but returned nil", s)
+		}
+	}
+}
+
+func Test_GetChecksums_NotPublished(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetStatusCode(404)
+
+	if _, err := GetChecksums(context.Background(), client, "https://kernel.ubuntu.com/mainline/v6.8.2/", nil); err == nil {
+		t.Errorf("GetChecksums() was supposed to return an error but returned nil")
+	}
+}
+
+func Test_getPackagesChecksums(t *testing.T) {
+	const packageURL = "https://kernel.ubuntu.com/mainline/v6.8.2/"
+
+	client := http.MockedClient{}
+	client.SetStatusCode(200)
+	client.SetURLResponse(packageURL+"amd64/CHECKSUMS", checksums682)
+
+	packages := []Package{
+		{Name: "linux-headers", Arch: "all", Subdir: "amd64"},
+		{Name: "linux-image-unsigned", Arch: "amd64", Subdir: "amd64"},
+	}
+
+	checksums, err := getPackagesChecksums(context.Background(), client, packageURL, packages, nil)
+	if err != nil {
+		t.Fatalf("getPackagesChecksums() returned an unexpected error: %v", err)
+	}
+	if len(checksums) != 2 {
+		t.Errorf("getPackagesChecksums() was supposed to return 2 checksums but returned %v", checksums)
+	}
+}
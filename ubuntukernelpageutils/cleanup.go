@@ -0,0 +1,106 @@
+package ubuntukernelpageutils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// InstalledRelease is a mainline kernel release with packages installed
+type InstalledRelease struct {
+	// Release is the kernel release e.g. "6.8.2-060802"
+	Release string
+	Version versionutils.KernelVersion
+	// DebianVersion is the newest Debian version of the release's
+	// packages e.g. 6.8.2-060802.202403271436, the way dpkg orders them
+	DebianVersion versionutils.DebianVersion
+	// Packages holds the installed packages of every flavour of the release
+	Packages []Package
+}
+
+// GroupInstalled groups the mainline packages of @packages by release,
+// the returned releases are sorted newest first by the Debian versions
+// of their packages so that they're ordered the way dpkg orders them
+func GroupInstalled(packages []Package) []InstalledRelease {
+	byRelease := map[string]*InstalledRelease{}
+	var releases []*InstalledRelease
+
+	for _, p := range packages {
+		if !p.IsMainline() {
+			continue
+		}
+
+		release := p.Release()
+		r, ok := byRelease[release]
+		if !ok {
+			version, err := versionutils.ParseKernelRelease(release)
+			if err != nil {
+				continue
+			}
+			r = &InstalledRelease{Release: release, Version: version}
+			byRelease[release] = r
+			releases = append(releases, r)
+		}
+		r.Packages = append(r.Packages, p)
+		if v, err := versionutils.ParseDebianVersion(p.Version); err == nil && r.DebianVersion.Less(v) {
+			r.DebianVersion = v
+		}
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		if c := releases[j].DebianVersion.Compare(releases[i].DebianVersion); c != 0 {
+			return c < 0
+		}
+		return releases[j].Version.Less(releases[i].Version)
+	})
+
+	grouped := make([]InstalledRelease, 0, len(releases))
+	for _, r := range releases {
+		grouped = append(grouped, *r)
+	}
+	return grouped
+}
+
+// CleanupPolicy selects the installed releases to keep
+type CleanupPolicy struct {
+	// Keep is the number of newest releases kept
+	Keep int
+	// Pinned releases are always kept
+	Pinned []versionutils.KernelVersion
+	// Running is the release of the running kernel, as printed
+	// by uname -r, which is always kept
+	Running string
+}
+
+// keeps returns whether @r is kept regardless of its age
+func (p CleanupPolicy) keeps(r InstalledRelease) bool {
+	if p.Running == r.Release || strings.HasPrefix(p.Running, r.Release+"-") {
+		return true
+	}
+	for _, v := range p.Pinned {
+		if v.Compare(r.Version) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectRemovable splits @releases, sorted newest first, into the ones to
+// remove and to keep according to @policy. The newest Keep releases are
+// kept, as are the running and pinned ones on top of them.
+func SelectRemovable(releases []InstalledRelease, policy CleanupPolicy) (remove, keep []InstalledRelease) {
+	kept := 0
+	for _, r := range releases {
+		switch {
+		case policy.keeps(r):
+			keep = append(keep, r)
+		case kept < policy.Keep:
+			keep = append(keep, r)
+			kept++
+		default:
+			remove = append(remove, r)
+		}
+	}
+	return remove, keep
+}
@@ -0,0 +1,109 @@
+package ubuntukernelpageutils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+func installedTestPackages() []Package {
+	var packages []Package
+	for _, fileName := range []string{
+		"linux-headers-6.7.9-060709_6.7.9-060709.202403061538_all.deb",
+		"linux-image-unsigned-6.7.9-060709-generic_6.7.9-060709.202403061538_amd64.deb",
+		"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		"linux-image-unsigned-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+		"linux-image-unsigned-6.9.0-060900rc7-generic_6.9.0-060900rc7.202405052133_amd64.deb",
+		"linux-image-unsigned-6.6.20-060620-generic_6.6.20-060620.202403010936_amd64.deb",
+		"linux-image-unsigned-6.8.1-060801-generic_6.8.1-060801.202403151937_amd64.deb",
+		// Ubuntu's own kernel
+		"linux-image-6.8.0-45-generic_6.8.0-45.45_amd64.deb",
+	} {
+		if p, ok := ParsePackageFileName(fileName); ok {
+			packages = append(packages, p)
+		}
+	}
+	return packages
+}
+
+func releaseNames(releases []InstalledRelease) []string {
+	names := []string{}
+	for _, r := range releases {
+		names = append(names, r.Release)
+	}
+	return names
+}
+
+func Test_GroupInstalled(t *testing.T) {
+	releases := GroupInstalled(installedTestPackages())
+
+	expected := []string{"6.9.0-060900rc7", "6.8.2-060802", "6.8.1-060801", "6.7.9-060709", "6.6.20-060620"}
+	if actual := releaseNames(releases); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GroupInstalled()\nExpected: %v,\nactual %v", expected, actual)
+	}
+	if len(releases[1].Packages) != 2 || len(releases[3].Packages) != 2 {
+		t.Errorf("GroupInstalled() was supposed to group packages of all flavours: %+v", releases)
+	}
+	if releases[0].Version != (versionutils.KernelVersion{Major: 6, Minor: 9, RC: 7}) {
+		t.Errorf("GroupInstalled() returned an unexpected version %+v", releases[0].Version)
+	}
+}
+
+func Test_GroupInstalled_DebianVersion(t *testing.T) {
+	var packages []Package
+	for _, fileName := range []string{
+		"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		// v6.8.2 rebuilt with a new ABI number
+		"linux-image-unsigned-6.8.2-060803-generic_6.8.2-060803.202404011200_amd64.deb",
+		"linux-headers-6.8.2-060803_6.8.2-060803.202404011200_all.deb",
+		"linux-image-unsigned-6.8.1-060801-generic_6.8.1-060801.202403151937_amd64.deb",
+	} {
+		if p, ok := ParsePackageFileName(fileName); ok {
+			packages = append(packages, p)
+		}
+	}
+
+	releases := GroupInstalled(packages)
+	expected := []string{"6.8.2-060803", "6.8.2-060802", "6.8.1-060801"}
+	if actual := releaseNames(releases); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("GroupInstalled()\nExpected: %v,\nactual %v", expected, actual)
+	}
+	if actual := releases[0].DebianVersion.String(); actual != "6.8.2-060803.202404011200" {
+		t.Errorf("GroupInstalled() returned an unexpected Debian version %q", actual)
+	}
+
+	remove, _ := SelectRemovable(releases, CleanupPolicy{Keep: 1})
+	if actual := releaseNames(remove); !reflect.DeepEqual(actual, expected[1:]) {
+		t.Errorf("SelectRemovable()\nExpected: %v,\nactual %v", expected[1:], actual)
+	}
+}
+
+func Test_SelectRemovable(t *testing.T) {
+	releases := GroupInstalled(installedTestPackages())
+	v6620, _ := versionutils.Parse("v6.6.20")
+
+	tests := []struct {
+		name     string
+		policy   CleanupPolicy
+		expected []string
+	}{
+		{"newest 2", CleanupPolicy{Keep: 2}, []string{"6.8.1-060801", "6.7.9-060709", "6.6.20-060620"}},
+		{"running", CleanupPolicy{Keep: 2, Running: "6.7.9-060709-generic"}, []string{"6.8.1-060801", "6.6.20-060620"}},
+		{"pinned", CleanupPolicy{Keep: 1, Pinned: []versionutils.KernelVersion{v6620}}, []string{"6.8.2-060802", "6.8.1-060801", "6.7.9-060709"}},
+		{"running only", CleanupPolicy{Running: "6.9.0-060900rc7-generic"}, []string{"6.8.2-060802", "6.8.1-060801", "6.7.9-060709", "6.6.20-060620"}},
+		{"all kept", CleanupPolicy{Keep: 10}, []string{}},
+		// 6.8.1-060801 mustn't be mistaken for 6.8.1-0608010
+		{"running prefix", CleanupPolicy{Keep: 1, Running: "6.8.1-0608010-generic"}, []string{"6.8.2-060802", "6.8.1-060801", "6.7.9-060709", "6.6.20-060620"}},
+	}
+
+	for _, tt := range tests {
+		remove, keep := SelectRemovable(releases, tt.policy)
+		if actual := releaseNames(remove); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("SelectRemovable() %s\nExpected: %v,\nactual %v", tt.name, tt.expected, actual)
+		}
+		if len(remove)+len(keep) != len(releases) {
+			t.Errorf("SelectRemovable() %s lost releases: %v, %v", tt.name, remove, keep)
+		}
+	}
+}
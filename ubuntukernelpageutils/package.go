@@ -0,0 +1,169 @@
+package ubuntukernelpageutils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of kernel packages published for each release
+const (
+	KindHeaders = "headers"
+	KindModules = "modules"
+	KindImage   = "image"
+)
+
+// e.g. linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb
+var regPackageFileName = regexp.MustCompile(`^(linux-(headers|modules|image-unsigned|image)-\d+\.\d+\.\d+-\d+(?:rc\d+)?(?:-([a-z0-9][a-z0-9-]*))?)_([^_]+)_([a-z0-9]+)\.deb$`)
+
+// e.g. linux-headers-6.8.2-060802 or linux-image-unsigned-6.9.0-060900rc7-generic
+var regPackageName = regexp.MustCompile(`^linux-(?:headers|modules|image-unsigned|image)-(\d+\.\d+\.\d+-(\d+)(?:rc\d+)?)(?:-|$)`)
+
+// Package describes a single kernel .deb published for a release
+type Package struct {
+	// Name of the package e.g. linux-image-unsigned-6.8.2-060802-generic
+	Name string
+	// Kind is one of KindHeaders, KindModules or KindImage
+	Kind string
+	// Flavour e.g. generic, lowlatency or generic-64k, empty
+	// for the architecture independent headers package
+	Flavour string
+	// Version is the Debian version e.g. 6.8.2-060802.202403271436
+	Version string
+	// Arch is the Debian architecture e.g. amd64 or all
+	Arch string
+	URL  string
+	// Subdir is the per architecture subdirectory of the release the
+	// package is published in e.g. amd64, empty for the flat layout
+	// where all .debs are published directly in the release directory
+	Subdir string
+}
+
+// FileName returns the name of the .deb file
+func (p Package) FileName() string {
+	return fmt.Sprintf("%s_%s_%s.deb", p.Name, p.Version, p.Arch)
+}
+
+// ParsePackageFileName parses a kernel .deb file name,
+// it returns false for files that are not kernel packages
+func ParsePackageFileName(fileName string) (Package, bool) {
+	m := regPackageFileName.FindStringSubmatch(fileName)
+	if m == nil {
+		return Package{}, false
+	}
+
+	kind := m[2]
+	if kind == "image-unsigned" {
+		kind = KindImage
+	}
+
+	return Package{
+		Name:    m[1],
+		Kind:    kind,
+		Flavour: m[3],
+		Version: m[4],
+		Arch:    m[5],
+	}, true
+}
+
+// Release returns the kernel release the package belongs to, which
+// is the same for all of its flavours e.g. "6.8.2-060802"
+func (p Package) Release() string {
+	if m := regPackageName.FindStringSubmatch(p.Name); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// IsMainline returns whether the package is a mainline kernel package
+// as opposed to one of Ubuntu's own kernels e.g. linux-image-6.8.0-45-generic,
+// mainline ABI numbers are the zero padded version e.g. 060802
+func (p Package) IsMainline() bool {
+	m := regPackageName.FindStringSubmatch(p.Name)
+	return m != nil && len(m[2]) == 6
+}
+
+// installRank orders packages so that each is installed after the ones it
+// depends on: the architecture independent headers, the flavour's headers,
+// modules and finally the image
+func (p Package) installRank() int {
+	switch {
+	case p.Kind == KindHeaders && p.Arch == "all":
+		return 0
+	case p.Kind == KindHeaders:
+		return 1
+	case p.Kind == KindModules:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// InstallOrder returns @packages sorted in the order they have to be installed in
+func InstallOrder(packages []Package) []Package {
+	sorted := append([]Package(nil), packages...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].installRank() < sorted[j].installRank() })
+	return sorted
+}
+
+// Matches returns whether the package is needed to install
+// the @flavour kernel on @arch
+func (p Package) Matches(arch, flavour string) bool {
+	if p.Arch == "all" {
+		// every architecture's subdirectory has its own copy
+		return p.Subdir == "" || p.Subdir == arch
+	}
+	return p.Arch == arch && p.Flavour == flavour
+}
+
+// Flavours returns the sorted flavours @packages were built in for @arch
+func Flavours(packages []Package, arch string) []string {
+	encountered := map[string]bool{}
+	flavours := []string{}
+
+	for _, p := range packages {
+		if p.Arch != arch || p.Flavour == "" || encountered[p.Flavour] {
+			continue
+		}
+		encountered[p.Flavour] = true
+		flavours = append(flavours, p.Flavour)
+	}
+
+	sort.Strings(flavours)
+	return flavours
+}
+
+// Archs returns the sorted architectures @packages were built for
+func Archs(packages []Package) []string {
+	encountered := map[string]bool{}
+	archs := []string{}
+
+	for _, p := range packages {
+		if p.Arch == "all" || encountered[p.Arch] {
+			continue
+		}
+		encountered[p.Arch] = true
+		archs = append(archs, p.Arch)
+	}
+
+	sort.Strings(archs)
+	return archs
+}
+
+// FlavourNotPublishedError is returned when no packages
+// of a flavour are published for an architecture
+type FlavourNotPublishedError struct {
+	Arch    string
+	Flavour string
+	// Published holds the flavours that are published for Arch
+	Published []string
+}
+
+func (e *FlavourNotPublishedError) Error() string {
+	if len(e.Published) == 0 {
+		return fmt.Sprintf("no .debs for %s are published", e.Arch)
+	}
+	return fmt.Sprintf("%s flavour is not published for %s, available flavours: %s",
+		e.Flavour, e.Arch, strings.Join(e.Published, ", "))
+}
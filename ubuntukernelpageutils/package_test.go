@@ -0,0 +1,224 @@
+package ubuntukernelpageutils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+)
+
+type parsePackageFileNameTestData struct {
+	fileName string
+	expected Package
+	ok       bool
+}
+
+func Test_ParsePackageFileName(t *testing.T) {
+	tests := []parsePackageFileNameTestData{
+		{
+			"linux-headers-4.12.4-041204_4.12.4-041204.201707271932_all.deb",
+			Package{Name: "linux-headers-4.12.4-041204", Kind: KindHeaders, Version: "4.12.4-041204.201707271932", Arch: "all"},
+			true,
+		},
+		{
+			"linux-headers-4.12.4-041204-generic-lpae_4.12.4-041204.201707271932_armhf.deb",
+			Package{Name: "linux-headers-4.12.4-041204-generic-lpae", Kind: KindHeaders, Flavour: "generic-lpae", Version: "4.12.4-041204.201707271932", Arch: "armhf"},
+			true,
+		},
+		{
+			"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+			Package{Name: "linux-image-unsigned-6.8.2-060802-generic", Kind: KindImage, Flavour: "generic", Version: "6.8.2-060802.202403271436", Arch: "amd64"},
+			true,
+		},
+		{
+			"linux-modules-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+			Package{Name: "linux-modules-6.8.2-060802-generic-64k", Kind: KindModules, Flavour: "generic-64k", Version: "6.8.2-060802.202403271436", Arch: "arm64"},
+			true,
+		},
+		{
+			"linux-image-unsigned-6.9.0-060900rc7-lowlatency_6.9.0-060900rc7.202405052133_amd64.deb",
+			Package{Name: "linux-image-unsigned-6.9.0-060900rc7-lowlatency", Kind: KindImage, Flavour: "lowlatency", Version: "6.9.0-060900rc7.202405052133", Arch: "amd64"},
+			true,
+		},
+		{"CHANGES", Package{}, false},
+		{"linux-libc-dev_6.8.2-060802.202403271436_amd64.deb", Package{}, false},
+		{"../", Package{}, false},
+	}
+
+	for _, tt := range tests {
+		actual, ok := ParsePackageFileName(tt.fileName)
+		if ok != tt.ok || actual != tt.expected {
+			t.Errorf("ParsePackageFileName(%q)\nExpected: %+v, %t,\nactual %+v, %t", tt.fileName, tt.expected, tt.ok, actual, ok)
+		}
+		if ok && actual.FileName() != tt.fileName {
+			t.Errorf("Package.FileName(): Expected: %q, actual %q", tt.fileName, actual.FileName())
+		}
+	}
+}
+
+var packagePage682 = packagePage(
+	"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+	"linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	"linux-modules-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	"linux-headers-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+	"linux-image-unsigned-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+	"linux-modules-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+	"linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+	"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+	"linux-modules-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+	"linux-headers-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+	"linux-image-unsigned-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+	"linux-modules-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+)
+
+// packageURLs returns the URLs of the @arch @flavour
+// packages linked from the release @page at @packageURL
+func packageURLs(page, packageURL, arch, flavour string) (urls []string) {
+	packages, _ := parsePackagesAndArchDirs(strings.NewReader(page), packageURL)
+	for _, p := range selectPackages(packages, arch, flavour) {
+		urls = append(urls, p.URL)
+	}
+	return urls
+}
+
+type selectPackagesFlavourTestData struct {
+	arch     string
+	flavour  string
+	expected []string
+}
+
+func Test_selectPackages_Flavours(t *testing.T) {
+	const packageURL = "https://kernel.ubuntu.com/mainline/v6.8.2/"
+
+	tests := []selectPackagesFlavourTestData{
+		{"amd64", "lowlatency", []string{
+			packageURL + "linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+			packageURL + "linux-headers-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+			packageURL + "linux-image-unsigned-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+			packageURL + "linux-modules-6.8.2-060802-lowlatency_6.8.2-060802.202403271436_amd64.deb",
+		}},
+		{"arm64", "generic", []string{
+			packageURL + "linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+			packageURL + "linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+			packageURL + "linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+			packageURL + "linux-modules-6.8.2-060802-generic_6.8.2-060802.202403271436_arm64.deb",
+		}},
+		{"arm64", "generic-64k", []string{
+			packageURL + "linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+			packageURL + "linux-headers-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+			packageURL + "linux-image-unsigned-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+			packageURL + "linux-modules-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+		}},
+		{"arm64", "lowlatency", []string{
+			packageURL + "linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+		}},
+	}
+
+	for _, tt := range tests {
+		actual := packageURLs(packagePage682, packageURL, tt.arch, tt.flavour)
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("packageURLs(%s, %s)\nExpected: %q,\nactual %q", tt.arch, tt.flavour, tt.expected, actual)
+		}
+	}
+}
+
+func Test_Flavours_Archs(t *testing.T) {
+	packages, archDirs := parsePackagesAndArchDirs(strings.NewReader(packagePage682), "")
+	if len(archDirs) != 0 {
+		t.Errorf("parsePackagesAndArchDirs() returned unexpected architecture directories %q", archDirs)
+	}
+
+	if actual, expected := Archs(packages), []string{"amd64", "arm64"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Archs()\nExpected: %q,\nactual %q", expected, actual)
+	}
+
+	flavours := map[string][]string{
+		"amd64": {"generic", "lowlatency"},
+		"arm64": {"generic", "generic-64k"},
+		"s390x": {},
+	}
+	for arch, expected := range flavours {
+		if actual := Flavours(packages, arch); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Flavours(%s)\nExpected: %q,\nactual %q", arch, expected, actual)
+		}
+	}
+}
+
+func Test_FlavourNotPublishedError(t *testing.T) {
+	err := &FlavourNotPublishedError{Arch: "arm64", Flavour: "lowlatency", Published: []string{"generic", "generic-64k"}}
+	const expected = "lowlatency flavour is not published for arm64, available flavours: generic, generic-64k"
+	if err.Error() != expected {
+		t.Errorf("FlavourNotPublishedError.Error()\nExpected: %q,\nactual %q", expected, err.Error())
+	}
+}
+
+func Test_DownloadKernelDebs_FlavourNotPublished(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(packagePage682)
+
+	_, err := DownloadKernelDebs(context.Background(), client, "https://kernel.ubuntu.com/mainline/v6.8.2/",
+		DownloadOptions{Arch: "arm64", Flavour: "lowlatency", Dir: t.TempDir()})
+
+	notPublished, ok := err.(*FlavourNotPublishedError)
+	if !ok {
+		t.Fatalf("DownloadKernelDebs() was supposed to return a *FlavourNotPublishedError but returned %v", err)
+	}
+	if expected := []string{"generic", "generic-64k"}; !reflect.DeepEqual(notPublished.Published, expected) {
+		t.Errorf("FlavourNotPublishedError.Published\nExpected: %q,\nactual %q", expected, notPublished.Published)
+	}
+}
+
+func Test_InstallOrder(t *testing.T) {
+	var packages []Package
+	for _, fileName := range []string{
+		"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		"linux-modules-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		"linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+	} {
+		p, _ := ParsePackageFileName(fileName)
+		packages = append(packages, p)
+	}
+
+	expected := []string{
+		"linux-headers-6.8.2-060802",
+		"linux-headers-6.8.2-060802-generic",
+		"linux-modules-6.8.2-060802-generic",
+		"linux-image-unsigned-6.8.2-060802-generic",
+	}
+
+	var actual []string
+	for _, p := range InstallOrder(packages) {
+		actual = append(actual, p.Name)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("InstallOrder()\nExpected: %v,\nactual %v", expected, actual)
+	}
+	if packages[0].Kind != KindImage {
+		t.Errorf("InstallOrder() wasn't supposed to modify its argument")
+	}
+}
+
+func Test_Package_Release(t *testing.T) {
+	tests := []struct {
+		name     string
+		release  string
+		mainline bool
+	}{
+		{"linux-headers-6.8.2-060802", "6.8.2-060802", true},
+		{"linux-headers-6.8.2-060802-generic", "6.8.2-060802", true},
+		{"linux-image-unsigned-6.9.0-060900rc7-lowlatency", "6.9.0-060900rc7", true},
+		{"linux-modules-6.8.2-060802-generic-64k", "6.8.2-060802", true},
+		{"linux-image-6.8.0-45-generic", "6.8.0-45", false},
+		{"linux-libc-dev", "", false},
+	}
+	for _, tt := range tests {
+		p := Package{Name: tt.name}
+		if p.Release() != tt.release || p.IsMainline() != tt.mainline {
+			t.Errorf("Package{Name: %q}\nExpected: %q, mainline %t,\nactual %q, mainline %t", tt.name, tt.release, tt.mainline, p.Release(), p.IsMainline())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ubuntukernelpageutils
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
+)
+
+// Release describes a single kernel version directory
+// published on Ubuntu's kernel ppa
+type Release struct {
+	Version versionutils.KernelVersion
+	// URL of the directory where the release's .debs are stored
+	URL string
+	// LastModified as shown on the directory listing,
+	// zero if it wasn't listed
+	LastModified time.Time
+	// Builds holds build results per architecture, it's only
+	// populated by functions looking at them e.g. ResolveBuiltKernelVersion
+	Builds map[string]BuildStatus
+}
+
+// ListReleases returns every release in @channel published on the
+// mainline archive at @baseURL (KernelWebpage if empty),
+// sorted from the oldest to the newest version
+func ListReleases(ctx context.Context, client http.Getter, baseURL string, channel Channel) ([]Release, error) {
+	releases, err := getKernelPage(ctx, client, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterReleases(releases, channel), nil
+}
+
+func filterReleases(releases []Release, channel Channel) []Release {
+	filtered := make([]Release, 0, len(releases))
+	for _, r := range releases {
+		if channel.Contains(r.Version) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func sortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].Version.Less(releases[j].Version) })
+}
+
+func releaseVersions(releases []Release) []versionutils.KernelVersion {
+	versions := make([]versionutils.KernelVersion, 0, len(releases))
+	for _, r := range releases {
+		versions = append(versions, r.Version)
+	}
+	return versions
+}
+
+func findRelease(releases []Release, version versionutils.KernelVersion) (Release, bool) {
+	for _, r := range releases {
+		if r.Version == version {
+			return r, true
+		}
+	}
+	return Release{}, false
+}
@@ -0,0 +1,111 @@
+package ubuntukernelpageutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// KernelPPAKeyFingerprint is the fingerprint of the "Kernel PPA
+// <kernel-ppa@canonical.com>" key signing mainline CHECKSUMS files
+const KernelPPAKeyFingerprint = "60AA7B6F30434AE68E569963E50C6A0917C622B0"
+
+// KeyserverURL is the keyserver the Kernel PPA key is fetched from
+const KeyserverURL = "https://keyserver.ubuntu.com/"
+
+const armoredSignaturePrefix = "-----BEGIN PGP SIGNATURE-----"
+
+// SignatureError is returned when CHECKSUMS can't
+// be trusted because its signature is missing or bad
+type SignatureError struct {
+	URL string
+	Err error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("Could not verify signature %s: %v", e.URL, e.Err)
+}
+
+// readKeyring reads an armored or binary OpenPGP keyring from @data
+func readKeyring(data []byte) (openpgp.EntityList, error) {
+	if bytes.Contains(data, []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// LoadKeyring reads the armored or binary OpenPGP keyring at @path
+// e.g. one exported with gpg --export
+func LoadKeyring(path string) (openpgp.EntityList, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := readKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read keyring %s: %v", path, err)
+	}
+	return keyring, nil
+}
+
+// GetKernelPPAKey fetches the Kernel PPA key from the keyserver
+// at @keyserverURL, making sure its fingerprint is KernelPPAKeyFingerprint
+func GetKernelPPAKey(ctx context.Context, client http.Getter, keyserverURL string) (openpgp.EntityList, error) {
+	if keyserverURL == "" {
+		keyserverURL = KeyserverURL
+	}
+	keyURL := resolveLink(keyserverURL, "pks/lookup?op=get&options=mr&search=0x"+KernelPPAKeyFingerprint)
+
+	data, err := getFile(ctx, client, keyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := readKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read Kernel PPA key from %s: %v", keyURL, err)
+	}
+
+	for _, e := range keyring {
+		if fingerprint(e) == KernelPPAKeyFingerprint {
+			return openpgp.EntityList{e}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s didn't return a key with fingerprint %s", keyURL, KernelPPAKeyFingerprint)
+}
+
+func fingerprint(e *openpgp.Entity) string {
+	return strings.ToUpper(fmt.Sprintf("%x", e.PrimaryKey.Fingerprint))
+}
+
+// verifySignature checks that @signature is a valid detached
+// signature of @signed made by a key from @keyring
+func verifySignature(keyring openpgp.EntityList, signed, signature []byte) (*openpgp.Entity, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte(armoredSignaturePrefix)) {
+		return openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(signature), nil)
+	}
+	return openpgp.CheckDetachedSignature(keyring, bytes.NewReader(signed), bytes.NewReader(signature), nil)
+}
+
+// getFile fetches @fileURL returning its contents,
+// non 200 status codes are considered an error
+func getFile(ctx context.Context, client http.Getter, fileURL string) ([]byte, error) {
+	resp, err := client.Get(ctx, fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("Could get %s, received error: %v", fileURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Received %v HTTP status code when downloading %s", resp.StatusCode, fileURL)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
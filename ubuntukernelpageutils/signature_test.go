@@ -0,0 +1,115 @@
+package ubuntukernelpageutils
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pmalek/kernel_deb_downloader/http"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newTestEntity(t *testing.T) *openpgp.Entity {
+	e, err := openpgp.NewEntity("Test", "", "test@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("openpgp.NewEntity() returned an unexpected error: %v", err)
+	}
+	return e
+}
+
+func armoredSignature(t *testing.T, signer *openpgp.Entity, signed string) string {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, signer, strings.NewReader(signed), nil); err != nil {
+		t.Fatalf("openpgp.ArmoredDetachSign() returned an unexpected error: %v", err)
+	}
+	return signature.String()
+}
+
+func armoredPublicKey(t *testing.T, e *openpgp.Entity) []byte {
+	var key bytes.Buffer
+	w, err := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor.Encode() returned an unexpected error: %v", err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatalf("Entity.Serialize() returned an unexpected error: %v", err)
+	}
+	w.Close()
+	return key.Bytes()
+}
+
+func Test_GetChecksums_Signature(t *testing.T) {
+	const dirURL = "https://kernel.ubuntu.com/mainline/v6.8.2/amd64/"
+
+	trusted, untrusted := newTestEntity(t), newTestEntity(t)
+
+	tests := []struct {
+		signature string
+		valid     bool
+	}{
+		{armoredSignature(t, trusted, checksums682), true},
+		{armoredSignature(t, untrusted, checksums682), false},
+		{armoredSignature(t, trusted, checksums682+"tampered"), false},
+		{"", false},
+	}
+
+	for i, tt := range tests {
+		client := http.MockedClient{}
+		client.SetStatusCode(200)
+		client.SetURLResponse(dirURL+"CHECKSUMS", checksums682)
+		client.SetURLResponse(dirURL+"CHECKSUMS.gpg", tt.signature)
+
+		checksums, err := GetChecksums(context.Background(), client, dirURL, openpgp.EntityList{trusted})
+		if tt.valid {
+			if err != nil || len(checksums) != 2 {
+				t.Errorf("GetChecksums() #%d returned unexpected %v, %v", i, checksums, err)
+			}
+		} else if _, ok := err.(*SignatureError); !ok {
+			t.Errorf("GetChecksums() #%d was supposed to return a *SignatureError but returned %v", i, err)
+		}
+	}
+}
+
+func Test_LoadKeyring(t *testing.T) {
+	e := newTestEntity(t)
+
+	path := filepath.Join(t.TempDir(), "keyring.asc")
+	if err := ioutil.WriteFile(path, armoredPublicKey(t, e), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("LoadKeyring() returned an unexpected error: %v", err)
+	}
+	if len(keyring) != 1 || fingerprint(keyring[0]) != fingerprint(e) {
+		t.Errorf("LoadKeyring() returned an unexpected keyring %v", keyring)
+	}
+}
+
+func Test_GetKernelPPAKey_WrongFingerprint(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetStatusCode(200)
+	client.SetResponse(string(armoredPublicKey(t, newTestEntity(t))))
+
+	if _, err := GetKernelPPAKey(context.Background(), client, ""); err == nil {
+		t.Errorf("GetKernelPPAKey() was supposed to reject a key with a different fingerprint but returned nil")
+	}
+}
+
+func Test_DownloadKernelDebs_NoKeyring(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(packagePage682)
+
+	_, err := DownloadKernelDebs(context.Background(), client, "https://kernel.ubuntu.com/mainline/v6.8.2/",
+		DownloadOptions{Arch: "amd64", Flavour: "generic", Dir: t.TempDir()})
+	if err == nil {
+		t.Errorf("DownloadKernelDebs() was supposed to refuse downloading without a keyring but returned nil")
+	}
+}
@@ -1,69 +1,103 @@
 package ubuntukernelpageutils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"regexp"
+	"net/url"
 	"sort"
-	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pmalek/kernel_deb_downloader/bytesize"
 	"github.com/pmalek/kernel_deb_downloader/download"
 	"github.com/pmalek/kernel_deb_downloader/http"
 	"github.com/pmalek/kernel_deb_downloader/versionutils"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"golang.org/x/net/html"
 )
 
-// KernelWebpage - URL pointing to ubuntu's ppa repositorty with Linux kernel's .deb packages
-const KernelWebpage = "http://kernel.ubuntu.com/~kernel-ppa/mainline/"
+// KernelWebpage - URL pointing to ubuntu's ppa repositorty with Linux kernel's .deb packages,
+// it's used whenever an empty base URL is passed in
+const KernelWebpage = "https://kernel.ubuntu.com/mainline/"
 
-func removeDuplicates(elements []string) []string {
-	encountered := map[string]bool{} // Use map to record duplicates as we find them.
-	result := []string{}
+// baseURLOrDefault returns @baseURL with a trailing slash
+// or KernelWebpage if @baseURL is empty
+func baseURLOrDefault(baseURL string) string {
+	if baseURL == "" {
+		return KernelWebpage
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return baseURL
+}
 
-	for v := range elements {
-		if encountered[elements[v]] == false {
-			encountered[elements[v]] = true      // Record this element as an encountered element.
-			result = append(result, elements[v]) // Append to result slice.
-		}
+// resolveLink resolves @href found on a page at @pageURL
+func resolveLink(pageURL, href string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL + href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return pageURL + href
 	}
-	return result
+	return base.ResolveReference(ref).String()
 }
 
-func parseKernelPage(respBody io.Reader) (links map[string]string) {
-	const padding = 2
+// lastModifiedLayout is the layout of dates on Apache's directory listings
+const lastModifiedLayout = "2006-01-02 15:04"
 
+func parseKernelPage(respBody io.Reader, baseURL string) (releases []Release) {
 	z := html.NewTokenizer(respBody)
 
-	links = make(map[string]string, 0) // the same as []string{}
+	encountered := map[versionutils.KernelVersion]bool{}
+	current := -1 // index of the release whose row is being parsed
 
 	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
-		if tt != html.StartTagToken {
-			continue
-		}
+		switch tt {
+		case html.StartTagToken:
+			for _, a := range z.Token().Attr {
+				if a.Key != "href" {
+					continue
+				}
 
-		for _, a := range z.Token().Attr {
-			if a.Key != "href" || versionutils.IsAnRCVersion(a.Val) == true {
+				current = -1
+				version, err := versionutils.Parse(a.Val)
+				if err != nil || encountered[version] {
+					continue
+				}
+
+				encountered[version] = true
+				releases = append(releases, Release{Version: version, URL: resolveLink(baseURL, a.Val)})
+				current = len(releases) - 1
+			}
+
+		case html.TextToken:
+			if current < 0 || !releases[current].LastModified.IsZero() {
 				continue
 			}
 
-			unifiedVersion := versionutils.UnifiedVersion(a.Val, padding)
-			if i, _ := strconv.Atoi(unifiedVersion[:padding]); i >= 3 && i < 10 {
-				links[unifiedVersion] = KernelWebpage + a.Val
+			text := strings.TrimSpace(string(z.Text()))
+			if t, err := time.Parse(lastModifiedLayout, text); err == nil {
+				releases[current].LastModified = t
 			}
 		}
 	}
 
-	return links
+	sortReleases(releases)
+	return releases
 }
 
-func parsePackagePage(respBody io.Reader, packageURL string) (links []string) {
-	regDebAll := regexp.MustCompile(`.*_all\.deb`)
-	regDebGeneric := regexp.MustCompile(`.*generic.*_amd64\.deb`)
-
+// parsePackagesAndArchDirs returns packages linked from a release page
+// and names of per architecture subdirectories it links to
+func parsePackagesAndArchDirs(respBody io.Reader, packageURL string) (packages []Package, archDirs []string) {
 	z := html.NewTokenizer(respBody)
+	encountered := map[string]bool{}
 
 	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
 
@@ -74,71 +108,343 @@ func parsePackagePage(respBody io.Reader, packageURL string) (links []string) {
 		t := z.Token()
 
 		for _, a := range t.Attr {
-			if a.Key == "href" && (regDebGeneric.MatchString(a.Val) || regDebAll.MatchString(a.Val)) {
-				links = append(links, packageURL+a.Val)
+			if a.Key != "href" || encountered[a.Val] {
+				continue
+			}
+			encountered[a.Val] = true
+
+			if arch := strings.TrimSuffix(a.Val, "/"); arch != a.Val && isSupportedArch(arch) {
+				archDirs = append(archDirs, arch)
 				break
 			}
+
+			if p, ok := ParsePackageFileName(a.Val); ok {
+				p.URL = resolveLink(packageURL, a.Val)
+				packages = append(packages, p)
+			}
+			break
 		}
 	}
 
-	links = removeDuplicates(links)
-	return
+	return packages, archDirs
 }
 
-func getMostActualKernelVersion(versionsAndLinksMap map[string]string) (version, link string) {
-	if len(versionsAndLinksMap) == 0 {
-		return
+func selectPackages(packages []Package, arch, flavour string) (selected []Package) {
+	for _, p := range packages {
+		if p.Matches(arch, flavour) {
+			selected = append(selected, p)
+		}
 	}
+	return selected
+}
 
-	var keys []string
-	for k := range versionsAndLinksMap {
-		keys = append(keys, k)
+func getMostActualKernelVersion(releases []Release) (version versionutils.KernelVersion, link string) {
+	if len(releases) == 0 {
+		return
 	}
-	sort.Strings(keys)
 
-	version = keys[len(keys)-1]
-	link = versionsAndLinksMap[keys[len(keys)-1]]
+	sortReleases(releases)
+	newest := releases[len(releases)-1]
 
-	return
+	return newest.Version, newest.URL
 }
 
-// GetMostActualKernelVersion returns a pair of strings representing
-// version - a canonical kernel version e.g. 040602
+// GetMostActualKernelVersion returns
+// version - the newest kernel version in @channel e.g. v4.6.2
 // link - a URL where kernel .debs at version @version are stored
-func GetMostActualKernelVersion(client http.Getter) (version, link string, err error) {
-	resp, err := client.Get(KernelWebpage)
+// from the mainline archive at @baseURL (KernelWebpage if empty)
+func GetMostActualKernelVersion(ctx context.Context, client http.Getter, baseURL string, channel Channel) (version versionutils.KernelVersion, link string, err error) {
+	releases, err := ListReleases(ctx, client, baseURL, channel)
+	if err != nil {
+		return versionutils.KernelVersion{}, "", err
+	}
+
+	version, link = getMostActualKernelVersion(releases)
+	return version, link, nil
+}
+
+func getKernelPage(ctx context.Context, client http.Getter, baseURL string) ([]Release, error) {
+	baseURL = baseURLOrDefault(baseURL)
+
+	resp, err := client.Get(ctx, baseURL)
 	if err != nil {
-		return "", "",
-			fmt.Errorf("Could get Ubuntu kernel mainline webpage %s, received error: %v", KernelWebpage, err)
+		return nil, fmt.Errorf("Could get Ubuntu kernel mainline webpage %s, received error: %v", baseURL, err)
 	}
 	defer resp.Body.Close()
 
-	links := parseKernelPage(resp.Body)
-	version, link = getMostActualKernelVersion(links)
+	// error pages would be parsed as an empty index
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Received %v HTTP status code when downloading Ubuntu kernel mainline webpage %s", resp.StatusCode, baseURL)
+	}
+
+	return parseKernelPage(resp.Body, baseURL), nil
+}
+
+// ResolveKernelVersion returns the kernel version (and a URL where its
+// .debs are stored) picked by @constraint from the versions in @channel
+// published on Ubuntu's kernel ppa, see versionutils.Constraint for the
+// supported expressions e.g. "~6.6", ">=6.8 <6.10" or "previous".
+// @baseURL is the mainline archive's URL, KernelWebpage if empty.
+func ResolveKernelVersion(ctx context.Context, client http.Getter, baseURL, constraint string, channel Channel) (version versionutils.KernelVersion, link string, err error) {
+	c, err := versionutils.ParseConstraint(constraint)
+	if err != nil {
+		return versionutils.KernelVersion{}, "", err
+	}
+
+	releases, err := ListReleases(ctx, client, baseURL, channel)
+	if err != nil {
+		return versionutils.KernelVersion{}, "", err
+	}
+
+	version, link, ok := resolveKernelVersion(releases, c)
+	if !ok {
+		return versionutils.KernelVersion{}, "",
+			fmt.Errorf("No %v kernel version on %s matches %q", channel, baseURLOrDefault(baseURL), constraint)
+	}
 	return version, link, nil
 }
 
-// DownloadKernelDebs downloads Linux kernel .debs from @actualPackageURL
-// to the current directory
-func DownloadKernelDebs(client http.GetterHeader, packageURL string) ([]string, error) {
-	resp, err := client.Get(packageURL)
+func resolveKernelVersion(releases []Release, c versionutils.Constraint) (version versionutils.KernelVersion, link string, ok bool) {
+	version, ok = c.Select(releaseVersions(releases))
+	if !ok {
+		return versionutils.KernelVersion{}, "", false
+	}
+
+	r, _ := findRelease(releases, version)
+	return version, r.URL, true
+}
+
+// VersionNotFoundError is returned when a requested kernel version
+// is not published on Ubuntu's kernel ppa
+type VersionNotFoundError struct {
+	Version versionutils.KernelVersion
+	// Near holds published versions closest to Version
+	Near []versionutils.KernelVersion
+}
+
+func (e *VersionNotFoundError) Error() string {
+	msg := fmt.Sprintf("kernel version %v is not available", e.Version)
+	if len(e.Near) == 0 {
+		return msg
+	}
+
+	near := make([]string, 0, len(e.Near))
+	for _, v := range e.Near {
+		near = append(near, v.String())
+	}
+	return msg + ", near matches: " + strings.Join(near, ", ")
+}
+
+// GetKernelVersion returns the kernel version @version e.g. "v6.8.2" or
+// "6.8.2" (and a URL where its .debs are stored) after validating that
+// it is published on Ubuntu's kernel ppa. Release candidates can be pinned
+// as well. When it is not published, the returned error is
+// a *VersionNotFoundError listing near matches.
+// @baseURL is the mainline archive's URL, KernelWebpage if empty.
+func GetKernelVersion(ctx context.Context, client http.Getter, baseURL, version string) (versionutils.KernelVersion, string, error) {
+	requested, err := versionutils.Parse(version)
+	if err != nil {
+		return versionutils.KernelVersion{}, "", err
+	}
+
+	releases, err := ListReleases(ctx, client, baseURL, ChannelWithRC)
+	if err != nil {
+		return versionutils.KernelVersion{}, "", err
+	}
+
+	return findKernelVersion(releases, requested)
+}
+
+func findKernelVersion(releases []Release, requested versionutils.KernelVersion) (versionutils.KernelVersion, string, error) {
+	if r, ok := findRelease(releases, requested); ok {
+		return r.Version, r.URL, nil
+	}
+
+	sortReleases(releases)
+
+	// "v4.1.13" is good enough for asking about "v4.1.13-wily"
+	if requested.Suffix == "" {
+		for _, r := range releases {
+			v := r.Version
+			if v.Major == requested.Major && v.Minor == requested.Minor &&
+				v.Patch == requested.Patch && v.RC == requested.RC {
+				return v, r.URL, nil
+			}
+		}
+	}
+
+	return versionutils.KernelVersion{}, "", &VersionNotFoundError{
+		Version: requested,
+		Near:    nearKernelVersions(releaseVersions(releases), requested, 3),
+	}
+}
+
+// nearKernelVersions returns up to @n versions on each side of @v
+// from sorted @versions
+func nearKernelVersions(versions []versionutils.KernelVersion, v versionutils.KernelVersion, n int) []versionutils.KernelVersion {
+	i := sort.Search(len(versions), func(i int) bool { return !versions[i].Less(v) })
+
+	from, to := i-n, i+n
+	if from < 0 {
+		from = 0
+	}
+	if to > len(versions) {
+		to = len(versions)
+	}
+	return versions[from:to]
+}
+
+// ListPackages returns all kernel packages published at @packageURL.
+// Both the flat layout, where .debs are published directly in the
+// release directory, and the layout with per architecture
+// subdirectories (e.g. amd64/) are supported.
+func ListPackages(ctx context.Context, client http.Getter, packageURL string) ([]Package, error) {
+	return listPackages(ctx, client, packageURL, nil)
+}
+
+// listPackages lists packages published at @packageURL looking only
+// into subdirectories of @archs or into all of them if @archs is nil
+func listPackages(ctx context.Context, client http.Getter, packageURL string, archs []string) ([]Package, error) {
+	packages, archDirs, err := getPackagePage(ctx, client, packageURL)
 	if err != nil {
-		fmt.Printf("Could get package webpage %s, received: %v\n", KernelWebpage, err)
 		return nil, err
 	}
+
+	for _, arch := range archDirs {
+		if archs != nil && !contains(archs, arch) {
+			continue
+		}
+
+		archURL := resolveLink(packageURL, arch+"/")
+		archPackages, _, err := getPackagePage(ctx, client, archURL)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range archPackages {
+			p.Subdir = arch
+			packages = append(packages, p)
+		}
+	}
+
+	return packages, nil
+}
+
+func getPackagePage(ctx context.Context, client http.Getter, pageURL string) ([]Package, []string, error) {
+	resp, err := client.Get(ctx, pageURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could get package webpage %s, received: %v", pageURL, err)
+	}
 	defer resp.Body.Close()
 
-	linksToDownload := parsePackagePage(resp.Body, packageURL)
-	filenames := download.ToFiles(client, linksToDownload)
-	return filenames, nil
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("Received %v HTTP status code when downloading package webpage %s", resp.StatusCode, pageURL)
+	}
+
+	packages, archDirs := parsePackagesAndArchDirs(resp.Body, pageURL)
+	return packages, archDirs, nil
+}
+
+func contains(elements []string, s string) bool {
+	for _, e := range elements {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DownloadOptions selects which .debs DownloadKernelDebs downloads
+// and where it puts them
+type DownloadOptions struct {
+	// Arch is the Debian architecture e.g. amd64
+	Arch string
+	// Flavour is the kernel flavour e.g. generic or lowlatency
+	Flavour string
+	// Dir is the directory .debs are downloaded to
+	Dir string
+	// SkipChecksums disables verifying .debs against
+	// the published CHECKSUMS files
+	SkipChecksums bool
+	// Keyring holds the keys CHECKSUMS files have to be signed with
+	Keyring openpgp.EntityList
+	// SkipSignature disables verifying signatures of CHECKSUMS files
+	// i.e. trusts them even when they aren't signed by Keyring
+	SkipSignature bool
+	// Workers is the number of .debs downloaded at once
+	Workers int
+	// Retry is the policy for retrying interrupted downloads
+	Retry http.RetryPolicy
+	// Cache, when set, keeps verified .debs across runs,
+	// it's not used when checksums are skipped
+	Cache download.Cache
+	// RateLimit is the number of bytes per second
+	// all .debs are downloaded with, 0 means unlimited
+	RateLimit bytesize.Size
+	// FileRateLimit is the number of bytes per second
+	// each .deb is downloaded with, 0 means unlimited
+	FileRateLimit bytesize.Size
+	// Progress reports the progress of downloads,
+	// nothing is reported when it's nil
+	Progress download.Progress
+}
+
+// DownloadKernelDebs downloads Linux kernel .debs of @opts.Flavour built
+// for architecture @opts.Arch from @packageURL to directory @opts.Dir,
+// verifying them against the published CHECKSUMS signed by @opts.Keyring.
+// When some of the .debs fail to download the returned results of all of
+// them are accompanied by a *download.Error.
+func DownloadKernelDebs(ctx context.Context, client http.GetterHeader, packageURL string, opts DownloadOptions) ([]download.Result, error) {
+	packages, err := listPackages(ctx, client, packageURL, []string{opts.Arch})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := selectPackages(packages, opts.Arch, opts.Flavour)
+	if len(Archs(selected)) == 0 {
+		return nil, &FlavourNotPublishedError{
+			Arch:      opts.Arch,
+			Flavour:   opts.Flavour,
+			Published: Flavours(packages, opts.Arch),
+		}
+	}
+
+	downloadOpts := download.Options{
+		Dir:           opts.Dir,
+		Workers:       opts.Workers,
+		Retry:         opts.Retry,
+		Cache:         opts.Cache,
+		RateLimit:     opts.RateLimit,
+		FileRateLimit: opts.FileRateLimit,
+		Progress:      opts.Progress,
+	}
+	if !opts.SkipChecksums {
+		keyring := opts.Keyring
+		if opts.SkipSignature {
+			keyring = nil
+		} else if len(keyring) == 0 {
+			return nil, errors.New("No keyring to verify CHECKSUMS signatures with was given")
+		}
+
+		if downloadOpts.Checksums, err = getPackagesChecksums(ctx, client, packageURL, selected, keyring); err != nil {
+			return nil, err
+		}
+	}
+
+	linksToDownload := make([]string, 0, len(selected))
+	for _, p := range selected {
+		linksToDownload = append(linksToDownload, p.URL)
+	}
+
+	return download.ToFiles(ctx, client, linksToDownload, downloadOpts)
 }
 
 // GetChangesFromPackageURL fetches CHANGES file contents from packageURL
 // and returns contents of this file and an error if not successful
-func GetChangesFromPackageURL(client http.Getter, packageURL string) (string, error) {
+func GetChangesFromPackageURL(ctx context.Context, client http.Getter, packageURL string) (string, error) {
 	changesURL := packageURL + "CHANGES"
 
-	response, err := client.Get(changesURL)
+	response, err := client.Get(ctx, changesURL)
 	if err != nil {
 		return "", err
 	} else if response.StatusCode != 200 {
@@ -1,145 +1,196 @@
 package ubuntukernelpageutils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pmalek/kernel_deb_downloader/http"
+	"github.com/pmalek/kernel_deb_downloader/versionutils"
 )
 
-func equalStringSlices(a, b []string) bool {
-	if a == nil && b == nil {
-		return true
-	}
-
-	if a == nil || b == nil {
-		return false
-	}
-
-	if len(a) != len(b) {
-		return false
-	}
-
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
+func mustParse(s string) versionutils.KernelVersion {
+	v, err := versionutils.Parse(s)
+	if err != nil {
+		panic(err)
 	}
-
-	return true
+	return v
 }
 
-type removeDuplicatesTestData struct {
-	str      []string
-	expected []string
-}
+// oldKernelWebpage is where the mainline archive was published
+// before moving to KernelWebpage
+const oldKernelWebpage = "http://kernel.ubuntu.com/~kernel-ppa/mainline/"
 
-func Test_RemoveDuplicates(t *testing.T) {
-	removeDuplicatesTests := []removeDuplicatesTestData{
-		{[]string{"aa", "bb", "cc", "aa", "aa", "aa", "aa", "aa"}, []string{"aa", "bb", "cc"}},
-		{[]string{"aa"}, []string{"aa"}},
-		{[]string{"aa", "bb", "cc", "aa"}, []string{"aa", "bb", "cc"}},
-		{[]string{"aa", "bb", "cc", "aa", "aa", "aa", "123"}, []string{"aa", "bb", "cc", "123"}},
-		{[]string{}, []string{}},
+func releasesFromLinks(links map[versionutils.KernelVersion]string) []Release {
+	releases := make([]Release, 0, len(links))
+	for v, link := range links {
+		releases = append(releases, Release{Version: v, URL: link})
 	}
+	sortReleases(releases)
+	return releases
+}
 
-	for _, tt := range removeDuplicatesTests {
-		actual := removeDuplicates(tt.str)
-		if !equalStringSlices(actual, tt.expected) {
-			t.Errorf("removeDuplicates(%q): Expected: %q, actual %q", tt.str, tt.expected, actual)
-		}
+func linksFromReleases(releases []Release) map[versionutils.KernelVersion]string {
+	links := make(map[versionutils.KernelVersion]string, len(releases))
+	for _, r := range releases {
+		links[r.Version] = r.URL
 	}
+	return links
 }
 
 type parsePagesTestData struct {
 	str      string
-	expected map[string]string
+	expected map[versionutils.KernelVersion]string
 }
 
 func Test_parseKernelPage(t *testing.T) {
 	tests := []parsePagesTestData{
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12.1/">v4.12.1/</a></td><td align="right">2017-07-12 17:20  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041201": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.12.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
 			},
 		},
 		{
 			`<tr><td valign="top"></td><td><a href="v4.12.1/">v4.12.1/</a></td><td align="right">2017-07-12 17:20  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041201": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.12.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
 			},
 		},
 		{
 			`<tr><td><a href="v4.12.1/">v4.12.1/</a></td><td align="right">2017-07-12 17:20  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041201": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.12.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
 			},
 		},
 		{
 			`<tr><td><a href="v4.12.1/">v4.12.1/</a></td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041201": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.12.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
 			},
 		},
 		{
 			`<tr><td><a href="v4.12.1/">v4.12.1/</a></td></tr>`,
-			map[string]string{
-				"041201": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.12.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.1/",
 			},
 		},
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12.4/">v4.12.4/</a></td><td align="right">2017-07-28 01:00  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.11.10/">v4.11.10/</a></td><td align="right">2017-07-12 16:20  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041110": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
-				"041204": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.11.10"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
+				mustParse("v4.12.4"):  "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
 			},
 		},
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v5.2.13/">v5.2.13/</a></td><td align="right">2020-07-28 01:00  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v5.3.10/">v5.3.10/</a></td><td align="right">2021-07-12 16:20  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"050213": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v5.2.13/",
-				"050310": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v5.3.10/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v5.2.13"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v5.2.13/",
+				mustParse("v5.3.10"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v5.3.10/",
+			},
+		},
+		{
+			`<tr><td><a href="../">Parent Directory</a></td></tr><tr><td><a href="v9.12.3/">v9.12.3/</a></td></tr><tr><td><a href="v10.1/">v10.1/</a></td></tr><tr><td><a href="daily/">daily/</a></td></tr>`,
+			map[versionutils.KernelVersion]string{
+				mustParse("v9.12.3"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v9.12.3/",
+				mustParse("v10.1"):   "http://kernel.ubuntu.com/~kernel-ppa/mainline/v10.1/",
 			},
 		},
 	}
 
 	for _, tt := range tests {
-		actual := parseKernelPage(strings.NewReader(tt.str))
+		actual := linksFromReleases(parseKernelPage(strings.NewReader(tt.str), oldKernelWebpage))
 		if !reflect.DeepEqual(actual, tt.expected) {
-			t.Errorf("parseKernelPage(%q)\nExpected: %q,\nactual %q", tt.str, tt.expected, actual)
+			t.Errorf("parseKernelPage(%q)\nExpected: %v,\nactual %v", tt.str, tt.expected, actual)
 		}
 	}
 }
 
+func Test_parseKernelPage_AllEntriesAreReturned(t *testing.T) {
+	const page = `<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/~kernel-ppa/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12.4/">v4.12.4/</a></td><td align="right">2017-07-28 01:00  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.11.10/">v4.11.10/</a></td><td align="right">2017-07-12 16:20  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12-rc3/">v4.12-rc3/</a></td><td align="right">2017-05-29 02:50  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td><a href="v4.12.4/">v4.12.4/</a></td><td align="right">2017-07-29 01:00  </td></tr><tr><td><a href="v4.13/">v4.13/</a></td></tr>`
+
+	expected := []Release{
+		{Version: mustParse("v4.11.10"), URL: "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/", LastModified: time.Date(2017, 7, 12, 16, 20, 0, 0, time.UTC)},
+		{Version: mustParse("v4.12-rc3"), URL: "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12-rc3/", LastModified: time.Date(2017, 5, 29, 2, 50, 0, 0, time.UTC)},
+		{Version: mustParse("v4.12.4"), URL: "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/", LastModified: time.Date(2017, 7, 28, 1, 0, 0, 0, time.UTC)},
+		{Version: mustParse("v4.13"), URL: "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.13/", LastModified: time.Time{}},
+	}
+
+	actual := parseKernelPage(strings.NewReader(page), oldKernelWebpage)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("parseKernelPage()\nExpected: %v,\nactual %v", expected, actual)
+	}
+}
+
 func Test_parseKernelPage_RCsAreNotReturned(t *testing.T) {
 	tests := []parsePagesTestData{
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12.4/">v4.12.4/</a></td><td align="right">2017-07-28 01:00  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.11.10/">v4.11.10/</a></td><td align="right">2017-07-12 16:20  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12-rc3/">v4.12-rc3/</a></td><td align="right">2017-05-29 02:50  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041110": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
-				"041204": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.11.10"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
+				mustParse("v4.12.4"):  "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
 			},
 		},
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.11.10/">v4.11.10/</a></td><td align="right">2017-07-12 16:20  </td><td align="right">  - </td><td>&nbsp;</td></tr><tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12-rc3/">v4.12-rc3/</a></td><td align="right">2017-05-29 02:50  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{
-				"041110": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
+			map[versionutils.KernelVersion]string{
+				mustParse("v4.11.10"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.11.10/",
 			},
 		},
 		{
 			`<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="v4.12-rc3/">v4.12-rc3/</a></td><td align="right">2017-05-29 02:50  </td><td align="right">  - </td><td>&nbsp;</td></tr>`,
-			map[string]string{},
+			map[versionutils.KernelVersion]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		actual := linksFromReleases(filterReleases(parseKernelPage(strings.NewReader(tt.str), oldKernelWebpage), ChannelStable))
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("parseKernelPage(%q)\nExpected: %v,\nactual %v", tt.str, tt.expected, actual)
+		}
+	}
+}
+
+func Test_parseKernelPage_Channels(t *testing.T) {
+	const page = `<tr><td><a href="v6.8.12/">v6.8.12/</a></td></tr><tr><td><a href="v6.9-rc7/">v6.9-rc7/</a></td></tr><tr><td><a href="v6.9/">v6.9/</a></td></tr><tr><td><a href="v6.10-rc1/">v6.10-rc1/</a></td></tr>`
+
+	tests := []struct {
+		channel  Channel
+		expected map[versionutils.KernelVersion]string
+	}{
+		{
+			ChannelStable,
+			map[versionutils.KernelVersion]string{
+				mustParse("v6.8.12"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.12/",
+				mustParse("v6.9"):    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9/",
+			},
+		},
+		{
+			ChannelWithRC,
+			map[versionutils.KernelVersion]string{
+				mustParse("v6.8.12"):   "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.12/",
+				mustParse("v6.9-rc7"):  "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9-rc7/",
+				mustParse("v6.9"):      "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9/",
+				mustParse("v6.10-rc1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.10-rc1/",
+			},
+		},
+		{
+			ChannelRCOnly,
+			map[versionutils.KernelVersion]string{
+				mustParse("v6.9-rc7"):  "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9-rc7/",
+				mustParse("v6.10-rc1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.10-rc1/",
+			},
 		},
 	}
 
 	for _, tt := range tests {
-		actual := parseKernelPage(strings.NewReader(tt.str))
+		actual := linksFromReleases(filterReleases(parseKernelPage(strings.NewReader(page), oldKernelWebpage), tt.channel))
 		if !reflect.DeepEqual(actual, tt.expected) {
-			t.Errorf("parseKernelPage(%q)\nExpected: %q,\nactual %q", tt.str, tt.expected, actual)
+			t.Errorf("parseKernelPage(%v)\nExpected: %v,\nactual %v", tt.channel, tt.expected, actual)
 		}
 	}
 }
@@ -150,7 +201,7 @@ type parsePackangePageTestData struct {
 	expected   []string
 }
 
-func Test_parsePackagePage(t *testing.T) {
+func Test_parsePackagesAndArchDirs(t *testing.T) {
 	tests := []parsePackangePageTestData{
 		{
 			str: `<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/~kernel-ppa/mainline/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr>
@@ -277,62 +328,90 @@ func Test_parsePackagePage(t *testing.T) {
 		<tr><td valign="top"><img src="/icons/unknown.gif" alt="[    ]"></td><td><a href="linux-image-4.12.4-041204-lowlatency_4.12.4-041204.201707271932_amd64.deb">linux-image-4.12.4-041204-lowlatency_4.12.4-041204.201707271932_amd64.deb</a></td><td align="right">2017-07-27 23:51 </td><td align="right"> 49M</td><td>&nbsp;</td></tr>
 		<tr><td valign="top"><img src="/icons/unknown.gif" alt="[    ]"></td><td><a href="linux-image-4.12.4-041204-lowlatency_4.12.4-041204.201707271932_i386.deb">linux-image-4.12.4-041204-lowlatency_4.12.4-041204.201707271932_i386.deb</a></td><td align="right">2017-07-28 00:10  </td><td align="right"> 47M</td><td>&nbsp;</td></tr>`,
 			packageURL: "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
-			expected:   []string{},
+			expected:   nil,
 		},
 	}
 	for _, tt := range tests {
-		actual := parsePackagePage(strings.NewReader(tt.str), tt.packageURL)
+		actual := packageURLs(tt.str, tt.packageURL, "amd64", "generic")
 		if !reflect.DeepEqual(actual, tt.expected) {
-			t.Errorf("parsePackagePage(%q)\nExpected: %q,\nactual %q", tt.str, tt.expected, actual)
+			t.Errorf("packageURLs(%q)\nExpected: %q,\nactual %q", tt.str, tt.expected, actual)
 		}
 	}
+
+	const packageURL = "https://kernel.ubuntu.com/mainline/v6.8.2/"
+	packages, archDirs := parsePackagesAndArchDirs(strings.NewReader(packagePage("CHANGES", "amd64/", "arm64/", "daily/",
+		"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb")), packageURL)
+	if expected := []string{"amd64", "arm64"}; !reflect.DeepEqual(archDirs, expected) {
+		t.Errorf("parsePackagesAndArchDirs() architecture directories\nExpected: %q,\nactual %q", expected, archDirs)
+	}
+	if len(packages) != 1 || packages[0].URL != packageURL+"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb" {
+		t.Errorf("parsePackagesAndArchDirs() returned unexpected packages %+v", packages)
+	}
 }
 
 type getMostActualKernelVersionTestData struct {
-	links           map[string]string
-	expectedVersion string
+	links           map[versionutils.KernelVersion]string
+	expectedVersion versionutils.KernelVersion
 	expectedLink    string
 }
 
 func Test_getMostActualKernelVersion(t *testing.T) {
 	tests := []getMostActualKernelVersionTestData{
 		{
-			links: map[string]string{
-				"040116": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.16-wily/",
-				"040919": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.9.19/",
-				"041015": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.10.15/",
-				"040113": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/",
-				"040815": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
+			links: map[versionutils.KernelVersion]string{
+				mustParse("v4.1.16-wily"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.16-wily/",
+				mustParse("v4.9.19"):      "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.9.19/",
+				mustParse("v4.10.15"):     "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.10.15/",
+				mustParse("v4.1.13-wily"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/",
+				mustParse("v4.8.15"):      "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
 			},
-			expectedVersion: "041015",
+			expectedVersion: mustParse("v4.10.15"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.10.15/",
 		},
 		{
-			links: map[string]string{
-				"040113": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/",
-				"040815": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
+			links: map[versionutils.KernelVersion]string{
+				mustParse("v4.1.13-wily"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/",
+				mustParse("v4.8.15"):      "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
 			},
-			expectedVersion: "040815",
+			expectedVersion: mustParse("v4.8.15"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
 		},
 		{
-			links: map[string]string{
-				"040815": "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
+			links: map[versionutils.KernelVersion]string{
+				mustParse("v9.16.2"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v9.16.2/",
+				mustParse("v10.2"):   "http://kernel.ubuntu.com/~kernel-ppa/mainline/v10.2/",
+				mustParse("v10.1.7"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v10.1.7/",
+			},
+			expectedVersion: mustParse("v10.2"),
+			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v10.2/",
+		},
+		{
+			links: map[versionutils.KernelVersion]string{
+				mustParse("v4.8.15"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
 			},
-			expectedVersion: "040815",
+			expectedVersion: mustParse("v4.8.15"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.8.15/",
 		},
 		{
-			links:           map[string]string{},
-			expectedVersion: "",
+			links: map[versionutils.KernelVersion]string{
+				mustParse("v6.9-rc7"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9-rc7/",
+				mustParse("v6.9"):     "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9/",
+				mustParse("v6.9-rc6"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9-rc6/",
+			},
+			expectedVersion: mustParse("v6.9"),
+			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9/",
+		},
+		{
+			links:           map[versionutils.KernelVersion]string{},
+			expectedVersion: versionutils.KernelVersion{},
 			expectedLink:    "",
 		},
 	}
 
 	for _, tt := range tests {
-		actualVersion, actualLink := getMostActualKernelVersion(tt.links)
+		actualVersion, actualLink := getMostActualKernelVersion(releasesFromLinks(tt.links))
 		if actualVersion != tt.expectedVersion || actualLink != tt.expectedLink {
-			t.Errorf(" getMostActualKernelVersion(%q)\nExpected: %q, %q,\nactual %q, %q",
+			t.Errorf(" getMostActualKernelVersion(%v)\nExpected: %v, %q,\nactual %v, %q",
 				tt.links, tt.expectedVersion, tt.expectedLink, actualVersion, actualLink)
 		}
 	}
@@ -340,7 +419,7 @@ func Test_getMostActualKernelVersion(t *testing.T) {
 
 type GetMostActualKernelVersionTestData struct {
 	kernelPageContents string
-	expectedVersion    string
+	expectedVersion    versionutils.KernelVersion
 	expectedLink       string
 }
 
@@ -376,7 +455,7 @@ func Test_GetMostActualKernelVersion_MockClient(t *testing.T) {
 </table>
 <address>Apache/2.4.18 (Ubuntu) Server at kernel.ubuntu.com Port 80</address>
 </body></html>`,
-			expectedVersion: "041204",
+			expectedVersion: mustParse("v4.12.4"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
 		},
 		{
@@ -398,7 +477,7 @@ func Test_GetMostActualKernelVersion_MockClient(t *testing.T) {
 </table>
 <address>Apache/2.4.18 (Ubuntu) Server at kernel.ubuntu.com Port 80</address>
 </body></html>`,
-			expectedVersion: "041202",
+			expectedVersion: mustParse("v4.12.2"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.2/",
 		},
 		{
@@ -419,7 +498,7 @@ func Test_GetMostActualKernelVersion_MockClient(t *testing.T) {
 </table>
 <address>Apache/2.4.18 (Ubuntu) Server at kernel.ubuntu.com Port 80</address>
 </body></html>`,
-			expectedVersion: "041204",
+			expectedVersion: mustParse("v4.12.4"),
 			expectedLink:    "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
 		},
 	}
@@ -428,9 +507,9 @@ func Test_GetMostActualKernelVersion_MockClient(t *testing.T) {
 
 	for _, tt := range tests {
 		client.SetResponse(tt.kernelPageContents)
-		actualVersion, actualLink, err := GetMostActualKernelVersion(client)
+		actualVersion, actualLink, err := GetMostActualKernelVersion(context.Background(), client, oldKernelWebpage, ChannelStable)
 		if actualVersion != tt.expectedVersion || actualLink != tt.expectedLink || err != nil {
-			t.Errorf("GetMostActualKernelVersion()\nPage Contents:%q,\nExpected: %q, %q,\nactual %q, %q\nerror: %q",
+			t.Errorf("GetMostActualKernelVersion()\nPage Contents:%q,\nExpected: %v, %q,\nactual %v, %q\nerror: %q",
 				tt.kernelPageContents, tt.expectedVersion, tt.expectedLink, actualVersion, actualLink, err)
 		}
 	}
@@ -440,13 +519,25 @@ func Test_GetMostActualKernelVersion_MockClient_Error(t *testing.T) {
 	client := http.MockedClient{}
 	client.SetError(errors.New("Some error"))
 
-	actualVersion, actualLink, err := GetMostActualKernelVersion(client)
-	if actualVersion != "" || actualLink != "" || err == nil {
-		t.Errorf("GetMostActualKernelVersion()\nExpected empty version and link on error but received:\nactual %q, %q\nError: %q",
+	actualVersion, actualLink, err := GetMostActualKernelVersion(context.Background(), client, oldKernelWebpage, ChannelStable)
+	if actualVersion != (versionutils.KernelVersion{}) || actualLink != "" || err == nil {
+		t.Errorf("GetMostActualKernelVersion()\nExpected empty version and link on error but received:\nactual %v, %q\nError: %q",
 			actualVersion, actualLink, err)
 	}
 }
 
+func Test_ListReleases_ErrorStatus(t *testing.T) {
+	for _, status := range []int{404, 503} {
+		client := http.MockedClient{}
+		client.SetStatusCode(status)
+		client.SetResponse("<html><body><h1>Service Unavailable</h1></body></html>")
+
+		if releases, err := ListReleases(context.Background(), client, "", ChannelStable); err == nil {
+			t.Errorf("ListReleases() was supposed to return an error for HTTP status %d but returned %v", status, releases)
+		}
+	}
+}
+
 func Test_GetChangesFromPackageURL_OnSuccess(t *testing.T) {
 	expectedChanges := "Some Changes"
 
@@ -454,7 +545,7 @@ func Test_GetChangesFromPackageURL_OnSuccess(t *testing.T) {
 	client.SetResponse(expectedChanges)
 	client.SetStatusCode(200)
 
-	actualChangesContents, err := GetChangesFromPackageURL(client, "")
+	actualChangesContents, err := GetChangesFromPackageURL(context.Background(), client, "")
 	if err != nil {
 		t.Errorf("GetChangesFromPackageURL() wasn't supposed to return an error but it did return a %q", err)
 	}
@@ -467,7 +558,7 @@ func Test_GetChangesFromPackageURL_OnError(t *testing.T) {
 	client := http.MockedClient{}
 	client.SetError(fmt.Errorf("An Error"))
 
-	_, err := GetChangesFromPackageURL(client, "")
+	_, err := GetChangesFromPackageURL(context.Background(), client, "")
 
 	if err == nil {
 		t.Errorf("GetChangesFromPackageURL() was supposed to return an error but it returned an nil error")
@@ -478,7 +569,7 @@ func Test_GetChangesFromPackageURL_Non200StatusCode(t *testing.T) {
 	client := http.MockedClient{}
 	client.SetStatusCode(404)
 
-	_, err := GetChangesFromPackageURL(client, "")
+	_, err := GetChangesFromPackageURL(context.Background(), client, "")
 
 	if err == nil {
 		t.Errorf("GetChangesFromPackageURL() was supposed to return an error but it returned an nil error")
@@ -489,9 +580,304 @@ func Test_DownloadKernelDebs_Error(t *testing.T) {
 	client := http.MockedClient{}
 	client.SetError(errors.New(""))
 
-	_, err := DownloadKernelDebs(client, "")
+	_, err := DownloadKernelDebs(context.Background(), client, "", DownloadOptions{Arch: "amd64", Flavour: "generic", Dir: "."})
 
 	if err == nil {
 		t.Errorf("DownloadKernelDebs() was supposed to return an error but it returned an nil error")
 	}
 }
+
+type resolveKernelVersionTestData struct {
+	constraint      string
+	expectedVersion versionutils.KernelVersion
+	expectedLink    string
+	expectedOk      bool
+}
+
+func Test_resolveKernelVersion(t *testing.T) {
+	links := map[versionutils.KernelVersion]string{
+		mustParse("v6.1.91"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.1.91/",
+		mustParse("v6.6.31"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.6.31/",
+		mustParse("v6.9.2"):  "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9.2/",
+		mustParse("v6.10.1"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.10.1/",
+	}
+
+	tests := []resolveKernelVersionTestData{
+		{"latest", mustParse("v6.10.1"), "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.10.1/", true},
+		{"previous", mustParse("v6.9.2"), "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9.2/", true},
+		{"~6.6", mustParse("v6.6.31"), "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.6.31/", true},
+		{">=6.2 <6.10", mustParse("v6.9.2"), "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9.2/", true},
+		{"5.x", versionutils.KernelVersion{}, "", false},
+	}
+
+	for _, tt := range tests {
+		c, err := versionutils.ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) returned an unexpected error: %v", tt.constraint, err)
+		}
+
+		actualVersion, actualLink, ok := resolveKernelVersion(releasesFromLinks(links), c)
+		if actualVersion != tt.expectedVersion || actualLink != tt.expectedLink || ok != tt.expectedOk {
+			t.Errorf("resolveKernelVersion(%q)\nExpected: %v, %q, %t,\nactual %v, %q, %t",
+				tt.constraint, tt.expectedVersion, tt.expectedLink, tt.expectedOk, actualVersion, actualLink, ok)
+		}
+	}
+}
+
+func Test_ResolveKernelVersion_MockClient(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(`<tr><td><a href="v6.6.31/">v6.6.31/</a></td></tr><tr><td><a href="v6.9.2/">v6.9.2/</a></td></tr>`)
+
+	version, link, err := ResolveKernelVersion(context.Background(), client, "", "~6.6", ChannelStable)
+	if err != nil {
+		t.Fatalf("ResolveKernelVersion() returned an unexpected error: %v", err)
+	}
+	if version != mustParse("v6.6.31") || link != KernelWebpage+"v6.6.31/" {
+		t.Errorf("ResolveKernelVersion() returned %v, %q", version, link)
+	}
+
+	if _, _, err := ResolveKernelVersion(context.Background(), client, "", "~6.7", ChannelStable); err == nil {
+		t.Errorf("ResolveKernelVersion() was supposed to return an error when nothing matches but it returned nil")
+	}
+
+	if _, _, err := ResolveKernelVersion(context.Background(), client, "", "6.x.2", ChannelStable); err == nil {
+		t.Errorf("ResolveKernelVersion() was supposed to return an error on an invalid constraint but it returned nil")
+	}
+}
+
+func Test_findKernelVersion(t *testing.T) {
+	links := map[versionutils.KernelVersion]string{
+		mustParse("v4.1.13-wily"): "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/",
+		mustParse("v6.8.1"):       "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.1/",
+		mustParse("v6.8.2"):       "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.2/",
+		mustParse("v6.8.4"):       "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.4/",
+		mustParse("v6.9"):         "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.9/",
+	}
+
+	for _, s := range []string{"v6.8.2", "6.8.2", "v6.8.2/"} {
+		version, link, err := findKernelVersion(releasesFromLinks(links), mustParse(s))
+		if err != nil || version != mustParse("v6.8.2") || link != "http://kernel.ubuntu.com/~kernel-ppa/mainline/v6.8.2/" {
+			t.Errorf("findKernelVersion(%q) returned %v, %q, %v", s, version, link, err)
+		}
+	}
+
+	version, link, err := findKernelVersion(releasesFromLinks(links), mustParse("4.1.13"))
+	if err != nil || version != mustParse("v4.1.13-wily") || link != "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.1.13-wily/" {
+		t.Errorf("findKernelVersion(%q) returned %v, %q, %v", "4.1.13", version, link, err)
+	}
+
+	_, _, err = findKernelVersion(releasesFromLinks(links), mustParse("v6.8.3"))
+	notFound, ok := err.(*VersionNotFoundError)
+	if !ok {
+		t.Fatalf("findKernelVersion(%q) was supposed to return a *VersionNotFoundError but returned %v", "v6.8.3", err)
+	}
+
+	expectedNear := []versionutils.KernelVersion{mustParse("v4.1.13-wily"), mustParse("v6.8.1"), mustParse("v6.8.2"), mustParse("v6.8.4"), mustParse("v6.9")}
+	if !reflect.DeepEqual(notFound.Near, expectedNear) {
+		t.Errorf("VersionNotFoundError.Near\nExpected: %v,\nactual %v", expectedNear, notFound.Near)
+	}
+
+	const expectedMsg = "kernel version v6.8.3 is not available, near matches: v4.1.13-wily, v6.8.1, v6.8.2, v6.8.4, v6.9"
+	if notFound.Error() != expectedMsg {
+		t.Errorf("VersionNotFoundError.Error()\nExpected: %q,\nactual %q", expectedMsg, notFound.Error())
+	}
+}
+
+func Test_nearKernelVersions(t *testing.T) {
+	versions := []versionutils.KernelVersion{
+		mustParse("v6.1"), mustParse("v6.2"), mustParse("v6.3"), mustParse("v6.4"), mustParse("v6.5"),
+	}
+
+	tests := []struct {
+		version  string
+		expected []versionutils.KernelVersion
+	}{
+		{"v6.0", versions[:1]},
+		{"v6.3.1", versions[2:4]},
+		{"v7.0", versions[4:]},
+	}
+
+	for _, tt := range tests {
+		actual := nearKernelVersions(versions, mustParse(tt.version), 1)
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("nearKernelVersions(%q)\nExpected: %v,\nactual %v", tt.version, tt.expected, actual)
+		}
+	}
+}
+
+func Test_GetKernelVersion_MockClient(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(`<tr><td><a href="v6.8.1/">v6.8.1/</a></td></tr><tr><td><a href="v6.8.2/">v6.8.2/</a></td></tr>`)
+
+	version, link, err := GetKernelVersion(context.Background(), client, "", "v6.8.2")
+	if err != nil || version != mustParse("v6.8.2") || link != KernelWebpage+"v6.8.2/" {
+		t.Errorf("GetKernelVersion() returned %v, %q, %v", version, link, err)
+	}
+
+	if _, _, err := GetKernelVersion(context.Background(), client, "", "v6.8.3"); err == nil {
+		t.Errorf("GetKernelVersion() was supposed to return an error for a missing version but it returned nil")
+	}
+
+	if _, _, err := GetKernelVersion(context.Background(), client, "", "six"); err == nil {
+		t.Errorf("GetKernelVersion() was supposed to return an error for an invalid version but it returned nil")
+	}
+}
+
+func Test_GetKernelVersion_MockClient_RC(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(`<tr><td><a href="v6.9-rc7/">v6.9-rc7/</a></td></tr><tr><td><a href="v6.9/">v6.9/</a></td></tr>`)
+
+	version, link, err := GetKernelVersion(context.Background(), client, "", "v6.9-rc7")
+	if err != nil || version != mustParse("v6.9-rc7") || link != KernelWebpage+"v6.9-rc7/" {
+		t.Errorf("GetKernelVersion() returned %v, %q, %v", version, link, err)
+	}
+
+	version, _, err = GetMostActualKernelVersion(context.Background(), client, "", ChannelRCOnly)
+	if err != nil || version != mustParse("v6.9-rc7") {
+		t.Errorf("GetMostActualKernelVersion(ChannelRCOnly) returned %v, %v", version, err)
+	}
+}
+
+// packagePage returns a directory listing linking to @files
+func packagePage(files ...string) string {
+	page := `<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/~kernel-ppa/mainline/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr>`
+	for _, f := range files {
+		page += fmt.Sprintf(`<tr><td valign="top"><img src="/icons/unknown.gif" alt="[    ]"></td><td><a href="%s">%s</a></td><td align="right">2017-07-28 00:28  </td><td align="right">707K</td><td>&nbsp;</td></tr>`, f, f)
+	}
+	return page
+}
+
+var packagePage4124 = packagePage(
+	"CHANGES",
+	"linux-headers-4.12.4-041204_4.12.4-041204.201707271932_all.deb",
+	"linux-headers-4.12.4-041204-generic_4.12.4-041204.201707271932_amd64.deb",
+	"linux-image-4.12.4-041204-generic_4.12.4-041204.201707271932_amd64.deb",
+	"linux-headers-4.12.4-041204-generic_4.12.4-041204.201707271932_arm64.deb",
+	"linux-image-4.12.4-041204-generic_4.12.4-041204.201707271932_arm64.deb",
+	"linux-headers-4.12.4-041204-generic_4.12.4-041204.201707271932_ppc64el.deb",
+	"linux-image-4.12.4-041204-generic_4.12.4-041204.201707271932_ppc64el.deb",
+)
+
+func Test_selectPackages_Archs(t *testing.T) {
+	const packageURL = "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/"
+
+	for _, arch := range []string{"amd64", "arm64", "ppc64el"} {
+		expected := []string{
+			packageURL + "linux-headers-4.12.4-041204_4.12.4-041204.201707271932_all.deb",
+			packageURL + "linux-headers-4.12.4-041204-generic_4.12.4-041204.201707271932_" + arch + ".deb",
+			packageURL + "linux-image-4.12.4-041204-generic_4.12.4-041204.201707271932_" + arch + ".deb",
+		}
+
+		actual := packageURLs(packagePage4124, packageURL, arch, "generic")
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("packageURLs(%s)\nExpected: %q,\nactual %q", arch, expected, actual)
+		}
+	}
+}
+
+func Test_DownloadKernelDebs_ArchNotPublished(t *testing.T) {
+	client := http.MockedClient{}
+	client.SetResponse(packagePage4124)
+
+	_, err := DownloadKernelDebs(context.Background(), client, "http://kernel.ubuntu.com/~kernel-ppa/mainline/v4.12.4/",
+		DownloadOptions{Arch: "s390x", Flavour: "generic", Dir: t.TempDir()})
+	if err == nil {
+		t.Errorf("DownloadKernelDebs() was supposed to return an error for an architecture without .debs but it returned nil")
+	}
+}
+
+func Test_baseURLOrDefault(t *testing.T) {
+	tests := map[string]string{
+		"":                                     KernelWebpage,
+		"https://mirror.example.com/mainline":  "https://mirror.example.com/mainline/",
+		"https://mirror.example.com/mainline/": "https://mirror.example.com/mainline/",
+	}
+
+	for input, expected := range tests {
+		if actual := baseURLOrDefault(input); actual != expected {
+			t.Errorf("baseURLOrDefault(%q): Expected: %q, actual %q", input, expected, actual)
+		}
+	}
+}
+
+func Test_resolveLink(t *testing.T) {
+	tests := []struct{ page, href, expected string }{
+		{"https://kernel.ubuntu.com/mainline/", "v6.8.2/", "https://kernel.ubuntu.com/mainline/v6.8.2/"},
+		{"https://kernel.ubuntu.com/mainline/v6.8.2/", "amd64/", "https://kernel.ubuntu.com/mainline/v6.8.2/amd64/"},
+		{"https://kernel.ubuntu.com/mainline/v6.8.2/", "/mainline/", "https://kernel.ubuntu.com/mainline/"},
+		{"https://kernel.ubuntu.com/mainline/", "https://mirror.example.com/v6.8.2/", "https://mirror.example.com/v6.8.2/"},
+	}
+
+	for _, tt := range tests {
+		if actual := resolveLink(tt.page, tt.href); actual != tt.expected {
+			t.Errorf("resolveLink(%q, %q): Expected: %q, actual %q", tt.page, tt.href, tt.expected, actual)
+		}
+	}
+}
+
+func Test_ListReleases_BaseURL(t *testing.T) {
+	const baseURL = "https://mirror.example.com/mainline"
+
+	client := http.MockedClient{}
+	client.SetURLResponse(baseURL+"/", `<tr><td><a href="v6.8.2/">v6.8.2/</a></td></tr>`)
+
+	releases, err := ListReleases(context.Background(), client, baseURL, ChannelStable)
+	if err != nil {
+		t.Fatalf("ListReleases() returned an unexpected error: %v", err)
+	}
+
+	expected := []Release{{Version: mustParse("v6.8.2"), URL: baseURL + "/v6.8.2/"}}
+	if !reflect.DeepEqual(releases, expected) {
+		t.Errorf("ListReleases()\nExpected: %v,\nactual %v", expected, releases)
+	}
+}
+
+func Test_ListPackages_ArchSubdirectories(t *testing.T) {
+	const packageURL = "https://kernel.ubuntu.com/mainline/v6.8.2/"
+
+	client := http.MockedClient{}
+	client.SetURLResponse(packageURL, packagePage("CHANGES", "HEADER.html", "amd64/", "arm64/", "daily/"))
+	client.SetURLResponse(packageURL+"amd64/", packagePage(
+		"CHECKSUMS",
+		"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+		"linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		"linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	))
+	client.SetURLResponse(packageURL+"arm64/", packagePage(
+		"linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+		"linux-image-unsigned-6.8.2-060802-generic-64k_6.8.2-060802.202403271436_arm64.deb",
+	))
+
+	packages, err := ListPackages(context.Background(), client, packageURL)
+	if err != nil {
+		t.Fatalf("ListPackages() returned an unexpected error: %v", err)
+	}
+	if len(packages) != 5 {
+		t.Fatalf("ListPackages() was supposed to return 5 packages but returned %d: %+v", len(packages), packages)
+	}
+
+	if actual, expected := Flavours(packages, "arm64"), []string{"generic-64k"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Flavours(arm64)\nExpected: %q,\nactual %q", expected, actual)
+	}
+
+	var actual []string
+	for _, p := range selectPackages(packages, "amd64", "generic") {
+		actual = append(actual, p.URL)
+	}
+	expected := []string{
+		packageURL + "amd64/linux-headers-6.8.2-060802_6.8.2-060802.202403271436_all.deb",
+		packageURL + "amd64/linux-headers-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+		packageURL + "amd64/linux-image-unsigned-6.8.2-060802-generic_6.8.2-060802.202403271436_amd64.deb",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("selectPackages(amd64, generic)\nExpected: %q,\nactual %q", expected, actual)
+	}
+
+	packages, err = listPackages(context.Background(), client, packageURL, []string{"arm64"})
+	if err != nil {
+		t.Fatalf("listPackages() returned an unexpected error: %v", err)
+	}
+	if actual, expected := Archs(packages), []string{"arm64"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("listPackages(arm64) was supposed to look only into arm64/ but found %q", actual)
+	}
+}
@@ -0,0 +1,253 @@
+package versionutils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var regConstraintVersion = regexp.MustCompile(`^v?(\d+)(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-rc(\d+))?$`)
+
+type constraintOp string
+
+const (
+	opEQ     constraintOp = "="
+	opNE     constraintOp = "!="
+	opGT     constraintOp = ">"
+	opGE     constraintOp = ">="
+	opLT     constraintOp = "<"
+	opLE     constraintOp = "<="
+	opSeries constraintOp = "series"
+)
+
+// operators are ordered so that two character operators are tried first
+var constraintOps = []constraintOp{opGE, opLE, opNE, opGT, opLT, opEQ}
+
+type constraintTerm struct {
+	op      constraintOp
+	version KernelVersion
+	// anyMinor makes a series term match every minor version of a major
+	anyMinor bool
+}
+
+func (t constraintTerm) matches(v KernelVersion) bool {
+	v.Suffix = ""
+
+	switch t.op {
+	case opSeries:
+		return v.Major == t.version.Major && (t.anyMinor || v.Minor == t.version.Minor)
+	case opEQ:
+		return v.Compare(t.version) == 0
+	case opNE:
+		return v.Compare(t.version) != 0
+	case opGT:
+		return v.Compare(t.version) > 0
+	case opGE:
+		return v.Compare(t.version) >= 0
+	case opLT:
+		// "<6.10" is meant to exclude the whole 6.10 series,
+		// including its release candidates
+		if t.version.RC == 0 {
+			v.RC = 0
+		}
+		return v.Compare(t.version) < 0
+	case opLE:
+		return v.Compare(t.version) <= 0
+	}
+	return false
+}
+
+// Constraint selects kernel versions matching an expression like
+// "~6.6", ">=6.8 <6.10", "6.1.x latest", "latest" or "previous".
+//
+// An expression is a list of terms separated by spaces or commas which
+// all have to match:
+//   - "latest" selects the newest matching version (the default)
+//   - "previous" selects the newest version of the series preceding the
+//     newest matching series e.g. 6.9.12 when 6.10.3 is the newest
+//   - "=", "!=", ">", ">=", "<" and "<=" compare with a version, a missing
+//     minor or patch number is treated as 0; "<6.10" excludes 6.10 RCs
+//   - "6.x" or "6.1.x" match every version of a series
+//   - "~6.6" matches the 6.6 series, "~6.6.3" the 6.6 series from 6.6.3
+//   - a bare version like "6.8.2" matches exactly that version
+type Constraint struct {
+	raw      string
+	terms    []constraintTerm
+	previous bool
+}
+
+// ParseConstraint parses a constraint expression, see Constraint for
+// the supported syntax. An empty expression matches every version.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		switch strings.ToLower(field) {
+		case "latest":
+			continue
+		case "previous":
+			c.previous = true
+			continue
+		}
+
+		// allow a space between an operator and its version e.g. ">= 6.8"
+		if isConstraintOp(field) && i+1 < len(fields) {
+			i++
+			field += fields[i]
+		}
+
+		terms, err := parseConstraintTerm(field)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %v", s, err)
+		}
+		c.terms = append(c.terms, terms...)
+	}
+
+	return c, nil
+}
+
+func isConstraintOp(s string) bool {
+	for _, op := range constraintOps {
+		if s == string(op) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseConstraintTerm(s string) ([]constraintTerm, error) {
+	if strings.HasPrefix(s, "~") {
+		v, fields, wildcard, err := parseConstraintVersion(s[1:])
+		if err != nil {
+			return nil, err
+		} else if wildcard {
+			return nil, fmt.Errorf("%q: wildcards can't be combined with ~", s)
+		}
+
+		terms := []constraintTerm{{op: opSeries, version: v, anyMinor: fields == 1}}
+		if fields == 3 {
+			terms = append(terms, constraintTerm{op: opGE, version: v})
+		}
+		return terms, nil
+	}
+
+	for _, op := range constraintOps {
+		if !strings.HasPrefix(s, string(op)) {
+			continue
+		}
+
+		v, _, wildcard, err := parseConstraintVersion(s[len(op):])
+		if err != nil {
+			return nil, err
+		} else if wildcard {
+			return nil, fmt.Errorf("%q: wildcards can't be combined with %s", s, op)
+		}
+		return []constraintTerm{{op: op, version: v}}, nil
+	}
+
+	v, fields, wildcard, err := parseConstraintVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	if wildcard || fields == 1 {
+		return []constraintTerm{{op: opSeries, version: v, anyMinor: fields == 1}}, nil
+	}
+	return []constraintTerm{{op: opEQ, version: v}}, nil
+}
+
+// parseConstraintVersion parses a possibly partial version like "6",
+// "6.8", "6.8.x" or "v6.9-rc3". It returns the parsed version, the number
+// of numeric fields that were given and whether it ended with a wildcard.
+func parseConstraintVersion(s string) (v KernelVersion, fields int, wildcard bool, err error) {
+	m := regConstraintVersion.FindStringSubmatch(s)
+	if m == nil {
+		return KernelVersion{}, 0, false, fmt.Errorf("%q is not a valid version", s)
+	}
+
+	parts := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range m[1:4] {
+		switch {
+		case part == "":
+		case wildcard:
+			return KernelVersion{}, 0, false, fmt.Errorf("%q: a wildcard has to be the last part of a version", s)
+		case part == "x" || part == "X" || part == "*":
+			wildcard = true
+		default:
+			if *parts[i], err = strconv.Atoi(part); err != nil {
+				return KernelVersion{}, 0, false, fmt.Errorf("%q: %v", s, err)
+			}
+			fields++
+		}
+	}
+
+	if m[4] != "" {
+		if wildcard {
+			return KernelVersion{}, 0, false, fmt.Errorf("%q: wildcards can't be combined with an RC", s)
+		}
+		if v.RC, err = strconv.Atoi(m[4]); err != nil {
+			return KernelVersion{}, 0, false, fmt.Errorf("%q: %v", s, err)
+		}
+	}
+
+	return v, fields, wildcard, nil
+}
+
+// String returns the expression the constraint was parsed from
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Check returns whether v satisfies every term of the constraint
+func (c Constraint) Check(v KernelVersion) bool {
+	for _, t := range c.terms {
+		if !t.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the version from versions picked by the constraint
+// and false if none of them satisfies it
+func (c Constraint) Select(versions []KernelVersion) (KernelVersion, bool) {
+	candidates := c.Candidates(versions)
+	if len(candidates) == 0 {
+		return KernelVersion{}, false
+	}
+	return candidates[0], true
+}
+
+// Candidates returns versions satisfying the constraint in the order
+// of preference: newest first, so that callers can fall back to older
+// versions when the preferred one turns out to be unusable.
+// With "previous" the newest series is left out.
+func (c Constraint) Candidates(versions []KernelVersion) []KernelVersion {
+	var matching []KernelVersion
+	for _, v := range versions {
+		if c.Check(v) {
+			matching = append(matching, v)
+		}
+	}
+	if len(matching) == 0 {
+		return nil
+	}
+
+	sort.Slice(matching, func(i, j int) bool { return matching[j].Less(matching[i]) })
+
+	if !c.previous {
+		return matching
+	}
+
+	newest := matching[0]
+	for i, v := range matching {
+		if v.Major != newest.Major || v.Minor != newest.Minor {
+			return matching[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package versionutils
+
+import "testing"
+
+var constraintTestVersions = []string{
+	"v6.1.90", "v6.1.91", "v6.6.30", "v6.6.31", "v6.8.9", "v6.8.12",
+	"v6.9", "v6.9.1", "v6.9.2", "v6.10-rc3", "v6.10", "v6.10.1",
+}
+
+type constraintSelectTestData struct {
+	constraint string
+	expected   string
+	found      bool
+}
+
+func Test_Constraint_Select(t *testing.T) {
+	tests := []constraintSelectTestData{
+		{"", "v6.10.1", true},
+		{"latest", "v6.10.1", true},
+		{"~6.6", "v6.6.31", true},
+		{"~6.6.31", "v6.6.31", true},
+		{"~6.6.32", "", false},
+		{"~6", "v6.10.1", true},
+		{">=6.8 <6.10", "v6.9.2", true},
+		{">= 6.8, < 6.9", "v6.8.12", true},
+		{"<6.10", "v6.9.2", true},
+		{"<6.10-rc4", "v6.10-rc3", true},
+		{"6.1.x latest", "v6.1.91", true},
+		{"6.1.*", "v6.1.91", true},
+		{"6.x", "v6.10.1", true},
+		{"v6.8.9", "v6.8.9", true},
+		{"6.9", "v6.9", true},
+		{"=6.10-rc3", "v6.10-rc3", true},
+		{"!=6.10.1", "v6.10", true},
+		{">6.10.1", "", false},
+		{"<=6.1.90", "v6.1.90", true},
+		{"previous", "v6.9.2", true},
+		{"<6.9 previous", "v6.6.31", true},
+		{"6.1.x previous", "", false},
+		{"7.x", "", false},
+	}
+
+	versions := make([]KernelVersion, 0, len(constraintTestVersions))
+	for _, s := range constraintTestVersions {
+		versions = append(versions, mustParse(t, s))
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) returned an unexpected error: %v", tt.constraint, err)
+			continue
+		}
+
+		actual, found := c.Select(versions)
+		if found != tt.found {
+			t.Errorf("Select(%q): Expected found: %t, actual %t (%v)", tt.constraint, tt.found, found, actual)
+			continue
+		}
+		if found && actual.String() != tt.expected {
+			t.Errorf("Select(%q): Expected: %s, actual %s", tt.constraint, tt.expected, actual)
+		}
+	}
+}
+
+func Test_Constraint_Check_IgnoresSuffix(t *testing.T) {
+	c, err := ParseConstraint("4.1.13")
+	if err != nil {
+		t.Fatalf("ParseConstraint returned an unexpected error: %v", err)
+	}
+	if !c.Check(mustParse(t, "v4.1.13-wily")) {
+		t.Errorf("Expected v4.1.13-wily to satisfy %q", c)
+	}
+}
+
+func Test_ParseConstraint_Invalid(t *testing.T) {
+	for _, s := range []string{"foo", ">=", "~6.x", ">=6.x", "6.x.1", "6.8.2.1", "=>6.8"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q): Expected an error but received nil", s)
+		}
+	}
+}
+
+func Test_Constraint_Candidates(t *testing.T) {
+	versions := make([]KernelVersion, 0, len(constraintTestVersions))
+	for _, s := range constraintTestVersions {
+		versions = append(versions, mustParse(t, s))
+	}
+
+	tests := map[string][]string{
+		"~6.6":          {"v6.6.31", "v6.6.30"},
+		"<6.9 previous": {"v6.6.31", "v6.6.30", "v6.1.91", "v6.1.90"},
+		"7.x":           nil,
+	}
+
+	for constraint, expected := range tests {
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) returned an unexpected error: %v", constraint, err)
+		}
+
+		var actual []string
+		for _, v := range c.Candidates(versions) {
+			actual = append(actual, v.String())
+		}
+		if len(actual) != len(expected) {
+			t.Errorf("Candidates(%q): Expected: %q, actual %q", constraint, expected, actual)
+			continue
+		}
+		for i := range actual {
+			if actual[i] != expected[i] {
+				t.Errorf("Candidates(%q): Expected: %q, actual %q", constraint, expected, actual)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package versionutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DebianVersion is a Debian package version, [epoch:]upstream[-revision]
+// e.g. 6.8.2-060802.202403271436 is upstream 6.8.2 with revision
+// 060802.202403271436, see deb-version(7)
+type DebianVersion struct {
+	Epoch    int
+	Upstream string
+	// Revision is empty for native packages
+	Revision string
+}
+
+// ParseDebianVersion parses a Debian package version the way dpkg does:
+// the epoch is the part before the first colon, the revision the part
+// after the last hyphen and the upstream version has to start with a digit
+func ParseDebianVersion(s string) (DebianVersion, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DebianVersion{}, fmt.Errorf("empty Debian version")
+	}
+	if strings.ContainsAny(s, " \t\n") {
+		return DebianVersion{}, fmt.Errorf("Debian version %q contains whitespace", s)
+	}
+
+	var v DebianVersion
+	upstream := s
+	if colon := strings.IndexByte(s, ':'); colon >= 0 {
+		epoch, err := strconv.Atoi(s[:colon])
+		if err != nil || epoch < 0 || !isDigits(s[:colon]) {
+			return DebianVersion{}, fmt.Errorf("invalid epoch in Debian version %q", s)
+		}
+		v.Epoch, upstream = epoch, s[colon+1:]
+	}
+	if dash := strings.LastIndexByte(upstream, '-'); dash >= 0 {
+		if dash == len(upstream)-1 {
+			return DebianVersion{}, fmt.Errorf("empty revision in Debian version %q", s)
+		}
+		upstream, v.Revision = upstream[:dash], upstream[dash+1:]
+	}
+	v.Upstream = upstream
+
+	switch {
+	case v.Upstream == "":
+		return DebianVersion{}, fmt.Errorf("empty upstream version in Debian version %q", s)
+	case !isDigit(v.Upstream[0]):
+		return DebianVersion{}, fmt.Errorf("upstream version of Debian version %q doesn't start with a digit", s)
+	case strings.IndexFunc(v.Upstream, invalidIn(".+~-:")) >= 0:
+		return DebianVersion{}, fmt.Errorf("invalid character in upstream version of Debian version %q", s)
+	case strings.IndexFunc(v.Revision, invalidIn(".+~")) >= 0:
+		return DebianVersion{}, fmt.Errorf("invalid character in revision of Debian version %q", s)
+	}
+	return v, nil
+}
+
+// String returns the version as dpkg prints it, without a zero epoch
+func (v DebianVersion) String() string {
+	s := v.Upstream
+	if v.Epoch != 0 {
+		s = strconv.Itoa(v.Epoch) + ":" + s
+	}
+	if v.Revision != "" {
+		s += "-" + v.Revision
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 when v is respectively older, the same or
+// newer than o, following dpkg's ordering: epochs are compared as numbers,
+// then upstream versions and revisions alternating between non-digit
+// parts, compared character by character with ~ sorting before anything,
+// even the end of the part, and letters before other characters, and
+// numeric parts compared as numbers e.g. 1.0~rc1 < 1.0 < 1.0a < 1.0+b1 < 1.0.1
+func (v DebianVersion) Compare(o DebianVersion) int {
+	if c := compareInts(v.Epoch, o.Epoch); c != 0 {
+		return c
+	}
+	if c := compareDebianParts(v.Upstream, o.Upstream); c != 0 {
+		return c
+	}
+	return compareDebianParts(v.Revision, o.Revision)
+}
+
+// Less returns whether v is older than o
+func (v DebianVersion) Less(o DebianVersion) bool {
+	return v.Compare(o) < 0
+}
+
+// CompareDebianVersions parses and compares the Debian versions
+// @a and @b, see DebianVersion.Compare
+func CompareDebianVersions(a, b string) (int, error) {
+	va, err := ParseDebianVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseDebianVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// compareDebianParts implements dpkg's verrevcmp on upstream
+// versions or revisions @a and @b
+func compareDebianParts(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			if ac, bc := debianOrder(a), debianOrder(b); ac != bc {
+				return compareInts(ac, bc)
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		firstDiff := 0
+		for a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]) {
+			if firstDiff == 0 {
+				firstDiff = compareInts(int(a[0]), int(b[0]))
+			}
+			a, b = a[1:], b[1:]
+		}
+		// the longer number is the bigger one
+		if a != "" && isDigit(a[0]) {
+			return 1
+		}
+		if b != "" && isDigit(b[0]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// debianOrder returns the sort weight of the first character of @s
+// in a non-digit part, the end of the part weighs the same as a digit
+func debianOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case isLetter(s[0]):
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+// invalidIn returns a function reporting runes which
+// are neither alphanumeric ASCII nor in @allowed
+func invalidIn(allowed string) func(rune) bool {
+	return func(r rune) bool {
+		return r > 0x7f || !(isDigit(byte(r)) || isLetter(byte(r)) || strings.ContainsRune(allowed, r))
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return r > 0x7f || !isDigit(byte(r)) }) < 0
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
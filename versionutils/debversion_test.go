@@ -0,0 +1,203 @@
+package versionutils
+
+import "testing"
+
+func Test_ParseDebianVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected DebianVersion
+	}{
+		{"2.30", DebianVersion{Upstream: "2.30"}},
+		{"2.30-1ubuntu2", DebianVersion{Upstream: "2.30", Revision: "1ubuntu2"}},
+		{"1:2.30-1ubuntu2", DebianVersion{Epoch: 1, Upstream: "2.30", Revision: "1ubuntu2"}},
+		{"0:2.30", DebianVersion{Upstream: "2.30"}},
+		{"6.8.2-060802.202403271436", DebianVersion{Upstream: "6.8.2", Revision: "060802.202403271436"}},
+		{"6.9.0-060900rc7.202405051934", DebianVersion{Upstream: "6.9.0", Revision: "060900rc7.202405051934"}},
+		{"1.0-2-3", DebianVersion{Upstream: "1.0-2", Revision: "3"}},
+		{"1:2:3-4", DebianVersion{Epoch: 1, Upstream: "2:3", Revision: "4"}},
+		{"1.2.13.dfsg-1ubuntu4", DebianVersion{Upstream: "1.2.13.dfsg", Revision: "1ubuntu4"}},
+		{"4.5ubuntu1~16.04.1", DebianVersion{Upstream: "4.5ubuntu1~16.04.1"}},
+		{"30+20221128-1ubuntu1", DebianVersion{Upstream: "30+20221128", Revision: "1ubuntu1"}},
+		{" 1.0 ", DebianVersion{Upstream: "1.0"}},
+		{"10:0", DebianVersion{Epoch: 10, Upstream: "0"}},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseDebianVersion(tt.input)
+		if err != nil {
+			t.Errorf("ParseDebianVersion(%q) returned an unexpected error: %v", tt.input, err)
+		} else if actual != tt.expected {
+			t.Errorf("ParseDebianVersion(%q)\nExpected: %+v,\nactual %+v", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func Test_ParseDebianVersion_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		" ",
+		"1.0 2",
+		"1:",
+		":1.0",
+		"a:1.0",
+		"-1:1.0",
+		"+1:1.0",
+		"99999999999999999999:1.0",
+		"1.0-",
+		"-1",
+		"abc",
+		"v6.8.2",
+		"~1.0",
+		"1.0_1",
+		"1.0-1_2",
+		"1.0-1:2",
+		"1.0/2",
+		"1.0é",
+	}
+
+	for _, tt := range tests {
+		if v, err := ParseDebianVersion(tt); err == nil {
+			t.Errorf("ParseDebianVersion(%q) was supposed to return an error, actual %+v", tt, v)
+		}
+	}
+}
+
+func Test_DebianVersion_String(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1:2.30-1ubuntu2", "1:2.30-1ubuntu2"},
+		{"0:2.30-1", "2.30-1"},
+		{"2.30", "2.30"},
+		{"1.0-2-3", "1.0-2-3"},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseDebianVersion(tt.input)
+		if err != nil {
+			t.Fatalf("ParseDebianVersion(%q) returned an unexpected error: %v", tt.input, err)
+		}
+		if actual := v.String(); actual != tt.expected {
+			t.Errorf("ParseDebianVersion(%q).String()\nExpected: %q,\nactual %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func Test_DebianVersion_Compare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		// equal versions
+		{"0", "0", 0},
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.0-0", "1.0-00", 0},
+		{"0:1.0", "1.0", 0},
+		{"1.002", "1.2", 0},
+		{"1.0-1", "1.0-1", 0},
+		{"1:1.0-1", "1:1.0-1", 0},
+		{"00:1.0", "0:1.0", 0},
+
+		// epochs win over everything else
+		{"1:0", "0:1", 1},
+		{"1:1.0", "9.9", 1},
+		{"2:1.0", "1:9.9", 1},
+		{"10:1.0", "9:1.0", 1},
+
+		// numeric parts are compared as numbers
+		{"1.2.3", "1.2.10", -1},
+		{"1.10", "1.9", 1},
+		{"2.0", "2.0.0", -1},
+		{"10", "9", 1},
+		{"1.0.10", "1.0.9", 1},
+		{"1.0-10", "1.0-9", 1},
+
+		// tildes sort before everything, even the end of the part
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~~", "1.0~~a", -1},
+		{"1.0~~a", "1.0~", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~beta", "1.0~rc", -1},
+		{"0.9~rc1-1", "0.9-1", -1},
+		{"4.5ubuntu1~16.04.1", "4.5ubuntu1", -1},
+		{"1.0-1~bpo1", "1.0-1", -1},
+
+		// letters sort before other characters
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0+", -1},
+		{"1.0a", "1.0.", -1},
+		{"1.0+", "1.0.", -1},
+		{"1.0+b1", "1.0", 1},
+		{"1.0a", "1.0b", -1},
+		{"1.0A", "1.0a", -1},
+		{"1.0z", "1.0.1", -1},
+		{"7.6p2-4", "7.6-0", 1},
+		{"1.0.4-2", "1.0pre7-2", 1},
+		{"1.0.dfsg", "1.0+dfsg", 1},
+
+		// revisions
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-1", "1.0-1ubuntu1", -1},
+		{"1.0-1ubuntu1", "1.0-2", -1},
+		{"1.0-1ubuntu1", "1.0-1ubuntu2", -1},
+		{"1.0-1ubuntu1.1", "1.0-1ubuntu1", 1},
+		{"1.0-1ubuntu10", "1.0-1ubuntu9", 1},
+		{"1.0", "1.0-1", -1},
+		{"1.0-2-3", "1.0-2-2", 1},
+		{"1.0-2-1", "1.0-1-9", 1},
+
+		// libc6 and libssl on Ubuntu LTS releases
+		{"2.35-0ubuntu3.6", "2.38", -1},
+		{"2.39-0ubuntu8", "2.38", 1},
+		{"2.31-0ubuntu9.14", "2.35-0ubuntu3", -1},
+		{"3.0.2-0ubuntu1.15", "3.0.0", 1},
+		{"3.0.13-0ubuntu3", "3.0.2-0ubuntu1.15", 1},
+		{"1.1.1f-1ubuntu2.22", "3.0.0", -1},
+
+		// mainline kernel packages
+		{"6.8.2-060802.202403271436", "6.8.10-060810.202405171047", -1},
+		{"6.8.2-060802.202403271436", "6.8.2-060802.202404011200", -1},
+		{"6.9.0-060900rc7.202405051934", "6.9.0-060900.202405121743", -1},
+		{"6.9.0-060900rc6.202404281934", "6.9.0-060900rc7.202405051934", -1},
+		{"6.10.0-061000rc1.202405262132", "6.9.12-060912.202407271035", 1},
+		{"6.8.0-45.45", "6.8.2-060802.202403271436", -1},
+		{"4.15.0-213.224", "6.8.0-45.45", -1},
+	}
+
+	for _, tt := range tests {
+		a, err := ParseDebianVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseDebianVersion(%q) returned an unexpected error: %v", tt.a, err)
+		}
+		b, err := ParseDebianVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseDebianVersion(%q) returned an unexpected error: %v", tt.b, err)
+		}
+
+		if actual := a.Compare(b); actual != tt.expected {
+			t.Errorf("%q.Compare(%q)\nExpected: %v,\nactual %v", tt.a, tt.b, tt.expected, actual)
+		}
+		if actual := b.Compare(a); actual != -tt.expected {
+			t.Errorf("%q.Compare(%q)\nExpected: %v,\nactual %v", tt.b, tt.a, -tt.expected, actual)
+		}
+		if actual := a.Less(b); actual != (tt.expected < 0) {
+			t.Errorf("%q.Less(%q)\nExpected: %v,\nactual %v", tt.a, tt.b, tt.expected < 0, actual)
+		}
+	}
+}
+
+func Test_CompareDebianVersions(t *testing.T) {
+	if c, err := CompareDebianVersions("2.35-0ubuntu3.6", "2.38"); err != nil || c != -1 {
+		t.Errorf("CompareDebianVersions()\nExpected: -1,\nactual %v, %v", c, err)
+	}
+	if _, err := CompareDebianVersions("v2.35", "2.38"); err == nil {
+		t.Errorf("CompareDebianVersions() was supposed to return an error for an invalid version")
+	}
+	if _, err := CompareDebianVersions("2.35", "2.38-"); err == nil {
+		t.Errorf("CompareDebianVersions() was supposed to return an error for an invalid version")
+	}
+}
@@ -1,21 +1,161 @@
 package versionutils
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/pmalek/stringutils"
 )
 
 var regDigInVer = regexp.MustCompile(`\d+\.?`)
-var regRC = regexp.MustCompile(`.*-rc\d+-?.*`)
-var reg2digitsVersion = regexp.MustCompile(`v\d+\.\d+[^\.]*`)
-var reg3digitsVersion = regexp.MustCompile(`v\d+\.\d+\.\d+.*`)
+var regKernelVersion = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?(?:-rc(\d+))?(?:-([0-9A-Za-z.-]+))?/?$`)
+
+// KernelVersion represents a mainline kernel version as published
+// on Ubuntu's kernel ppa e.g. v4.6-rc7-wily is major 4, minor 6,
+// patch 0, RC 7 with a "wily" suffix
+type KernelVersion struct {
+	Major  int
+	Minor  int
+	Patch  int
+	RC     int    // release candidate number, 0 for final releases
+	Suffix string // distribution series e.g. "wily", empty if none
+	// ZeroPatch is whether a zero patch was written out e.g. v4.12.0,
+	// so that String returns the version as it was published
+	ZeroPatch bool
+}
+
+// Parse parses a kernel version string like "v4.12.4", "4.12.4/"
+// or "v4.6-rc7-wily" into a KernelVersion
+func Parse(s string) (KernelVersion, error) {
+	m := regKernelVersion.FindStringSubmatch(s)
+	if m == nil {
+		return KernelVersion{}, fmt.Errorf("%q is not a valid kernel version", s)
+	}
+
+	var (
+		v   KernelVersion
+		err error
+	)
+	if v.Major, err = strconv.Atoi(m[1]); err != nil {
+		return KernelVersion{}, fmt.Errorf("invalid major version in %q: %v", s, err)
+	}
+	if v.Minor, err = strconv.Atoi(m[2]); err != nil {
+		return KernelVersion{}, fmt.Errorf("invalid minor version in %q: %v", s, err)
+	}
+	if m[3] != "" {
+		if v.Patch, err = strconv.Atoi(m[3]); err != nil {
+			return KernelVersion{}, fmt.Errorf("invalid patch version in %q: %v", s, err)
+		}
+		v.ZeroPatch = v.Patch == 0
+	}
+	if m[4] != "" {
+		if v.RC, err = strconv.Atoi(m[4]); err != nil {
+			return KernelVersion{}, fmt.Errorf("invalid RC number in %q: %v", s, err)
+		}
+	}
+	v.Suffix = m[5]
+
+	return v, nil
+}
+
+// e.g. 6.8.2-060802-generic, 6.9.0-060900rc7-generic or 6.8.0-45-generic
+var regKernelRelease = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:-\d+(?:rc(\d+))?)?(?:[-+~_.].*)?$`)
+
+// ParseKernelRelease parses a kernel release as printed by uname -r into
+// the mainline version it's built from e.g. "6.8.2-060802-generic" is
+// v6.8.2 and "6.9.0-060900rc7-generic" is v6.9-rc7. Ubuntu's own kernels,
+// e.g. "6.8.0-45-generic", map to the mainline release they're based on.
+func ParseKernelRelease(release string) (KernelVersion, error) {
+	m := regKernelRelease.FindStringSubmatch(strings.TrimSpace(release))
+	if m == nil {
+		return KernelVersion{}, fmt.Errorf("%q is not a valid kernel release", release)
+	}
+
+	var v KernelVersion
+	for i, part := range []*int{&v.Major, &v.Minor, &v.Patch, &v.RC} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return KernelVersion{}, fmt.Errorf("invalid kernel release %q: %v", release, err)
+		}
+		*part = n
+	}
+	return v, nil
+}
+
+// String returns the version in the form used for mainline
+// directory names e.g. "v4.12.4", "v4.12", "v4.12.0" or "v4.6-rc7-wily"
+func (v KernelVersion) String() string {
+	s := fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+	if v.Patch != 0 || v.ZeroPatch {
+		s += fmt.Sprintf(".%d", v.Patch)
+	}
+	if v.RC != 0 {
+		s += fmt.Sprintf("-rc%d", v.RC)
+	}
+	if v.Suffix != "" {
+		s += "-" + v.Suffix
+	}
+	return s
+}
+
+// IsRC returns whether v is a release candidate
+func (v KernelVersion) IsRC() bool {
+	return v.RC != 0
+}
+
+// Compare returns -1, 0 or 1 when v is respectively older, the same
+// or newer than o. Release candidates are older than the final release
+// they precede e.g. v4.9-rc7 < v4.9. The suffix and whether a zero
+// patch was written out are ignored, v4.1.13-wily is the same release
+// as v4.1.13 and v4.12.0 as v4.12.
+func (v KernelVersion) Compare(o KernelVersion) int {
+	if c := compareInts(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	if v.RC != o.RC {
+		switch {
+		case v.RC == 0:
+			return 1
+		case o.RC == 0:
+			return -1
+		}
+		return compareInts(v.RC, o.RC)
+	}
+	return 0
+}
+
+// Less returns whether v is older than o
+func (v KernelVersion) Less(o KernelVersion) bool {
+	return v.Compare(o) < 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
 
 // UnifiedVersion returns a unified version string where each of
 // major, minor and patch parts of version string @s will have a @padding
 // number of characters without stripped of characters that are not digits
 // e.g. UnifiedVersion("004.004.004", 4) will return "000400040004"
+// It only keeps the first three numbers, use DebianVersion to compare
+// package versions.
 func UnifiedVersion(s string, padding int) string {
 	var ret string
 
@@ -33,8 +173,6 @@ func UnifiedVersion(s string, padding int) string {
 // IsAnRCVersion return a bool indicating whether @v is an RC version
 // e.g. returns true for "v4.6-rc7-wily", return false for "v4.5.0"
 func IsAnRCVersion(v string) bool {
-	if (reg2digitsVersion.MatchString(v) || reg3digitsVersion.MatchString(v)) && regRC.MatchString(v) == true {
-		return true
-	}
-	return false
+	kv, err := Parse(v)
+	return err == nil && kv.IsRC()
 }
@@ -54,3 +54,121 @@ func Test_IsAnRCVersion(t *testing.T) {
 		}
 	}
 }
+
+type parseTestData struct {
+	input    string
+	expected KernelVersion
+	err      bool
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []parseTestData{
+		{"v4.12.4", KernelVersion{Major: 4, Minor: 12, Patch: 4}, false},
+		{"v4.12.4/", KernelVersion{Major: 4, Minor: 12, Patch: 4}, false},
+		{"4.12.4", KernelVersion{Major: 4, Minor: 12, Patch: 4}, false},
+		{"v4.12", KernelVersion{Major: 4, Minor: 12}, false},
+		{"v4.12.0", KernelVersion{Major: 4, Minor: 12, ZeroPatch: true}, false},
+		{"v10.1.2", KernelVersion{Major: 10, Minor: 1, Patch: 2}, false},
+		{"v4.6-rc7-wily/", KernelVersion{Major: 4, Minor: 6, RC: 7, Suffix: "wily"}, false},
+		{"v4.1.9-unstable", KernelVersion{Major: 4, Minor: 1, Patch: 9, Suffix: "unstable"}, false},
+		{"v4.8-rc1", KernelVersion{Major: 4, Minor: 8, RC: 1}, false},
+		{"linux", KernelVersion{}, true},
+		{"../", KernelVersion{}, true},
+		{"v4", KernelVersion{}, true},
+		{"", KernelVersion{}, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := Parse(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse(%q): Expected error: %t, actual error: %v", tt.input, tt.err, err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("Parse(%q): Expected: %+v, actual %+v", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func Test_ParseKernelRelease(t *testing.T) {
+	tests := []parseTestData{
+		{"6.8.2-060802-generic", KernelVersion{Major: 6, Minor: 8, Patch: 2}, false},
+		{"6.8.2-060802-generic\n", KernelVersion{Major: 6, Minor: 8, Patch: 2}, false},
+		{"6.9.0-060900rc7-lowlatency", KernelVersion{Major: 6, Minor: 9, RC: 7}, false},
+		{"6.8.0-45-generic", KernelVersion{Major: 6, Minor: 8}, false},
+		{"5.15.0-1034-raspi", KernelVersion{Major: 5, Minor: 15}, false},
+		{"6.1.0-18-amd64", KernelVersion{Major: 6, Minor: 1}, false},
+		{"6.7.9", KernelVersion{Major: 6, Minor: 7, Patch: 9}, false},
+		{"6.7.9+", KernelVersion{Major: 6, Minor: 7, Patch: 9}, false},
+		{"6.6.21-0-lts", KernelVersion{Major: 6, Minor: 6, Patch: 21}, false},
+		{"6.8.1-arch1-1", KernelVersion{Major: 6, Minor: 8, Patch: 1}, false},
+		{"linux", KernelVersion{}, true},
+		{"6", KernelVersion{}, true},
+		{"", KernelVersion{}, true},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseKernelRelease(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseKernelRelease(%q): Expected error: %t, actual error: %v", tt.input, tt.err, err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("ParseKernelRelease(%q): Expected: %+v, actual %+v", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func Test_KernelVersion_String(t *testing.T) {
+	for _, s := range []string{"v4.12.4", "v4.12", "v4.12.0", "v6.9.0-rc1", "v4.6-rc7-wily", "v10.0.1", "v4.1.9-unstable"} {
+		v, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned an unexpected error: %v", s, err)
+		}
+		if actual := v.String(); actual != s {
+			t.Errorf("Parse(%q).String(): Expected: %q, actual %q", s, s, actual)
+		}
+	}
+}
+
+type compareTestData struct {
+	a, b     string
+	expected int
+}
+
+func Test_KernelVersion_Compare(t *testing.T) {
+	tests := []compareTestData{
+		{"v4.12.4", "v4.12.4", 0},
+		{"v4.12.4", "v4.12.10", -1},
+		{"v4.9.19", "v4.10.15", -1},
+		{"v9.9.9", "v10.0", -1},
+		{"v10.0", "v9.9.9", 1},
+		{"v6.9-rc7", "v6.9", -1},
+		{"v6.9", "v6.9-rc7", 1},
+		{"v6.9-rc2", "v6.9-rc10", -1},
+		{"v6.8.12", "v6.9-rc1", -1},
+		{"v4.1.13-wily", "v4.1.13-wily", 0},
+		{"v4.1.13", "v4.1.13-wily", 0},
+		{"v4.1.13-wily", "v4.1.14", -1},
+		{"v4.12", "v4.12.0", 0},
+		{"v4.12.0", "v4.12.1", -1},
+	}
+
+	for _, tt := range tests {
+		a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+		if actual := a.Compare(b); actual != tt.expected {
+			t.Errorf("%s.Compare(%s): Expected: %d, actual %d", tt.a, tt.b, tt.expected, actual)
+		}
+		if actual := a.Less(b); actual != (tt.expected < 0) {
+			t.Errorf("%s.Less(%s): Expected: %t, actual %t", tt.a, tt.b, tt.expected < 0, actual)
+		}
+	}
+}
+
+func mustParse(t *testing.T, s string) KernelVersion {
+	v, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned an unexpected error: %v", s, err)
+	}
+	return v
+}